@@ -0,0 +1,135 @@
+// Command lshforest-bench reports the recall@k, queries per second, latency,
+// candidates examined per query and memory of an LSHForest built from a
+// dataset of vectors.
+//
+// Usage:
+//
+//	lshforest-bench -data vectors.txt -queries queries.txt -l 10 -maxk 20 -k 10
+//
+// Each file holds one vector per line with its components separated by
+// whitespace or commas. Without -queries, the first -n vectors of the dataset
+// are used as queries.
+//
+// With -recall, the command instead searches for the cheapest l, maxk,
+// multiplier and number of probes which reach the given recall@k within the
+// -memory and -latency budgets, and prints the trade-off curve it explored
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/justinfargnoli/lshforest/pkg"
+	"github.com/justinfargnoli/lshforest/pkg/bench"
+	"os"
+	"time"
+)
+
+// flags are the command line flags of the command
+type flags struct {
+	data, queries, metric string
+	n                     int
+	l, maxK, k, workers   uint
+	multiplier, probes    uint
+	seed                  int64
+	recall                float64
+	memory                uint64
+	latency               time.Duration
+}
+
+func main() {
+	var f flags
+	flag.StringVar(&f.data, "data", "", "file of the vectors to index")
+	flag.StringVar(&f.queries, "queries", "", "file of the query vectors")
+	flag.IntVar(&f.n, "n", 100,
+		"number of dataset vectors to query without -queries")
+	flag.UintVar(&f.l, "l", 10, "number of trees")
+	flag.UintVar(&f.maxK, "maxk", 20,
+		"maximum number of hash functions per tree")
+	flag.UintVar(&f.k, "k", 10, "number of neighbors per query")
+	flag.UintVar(&f.multiplier, "multiplier", 0,
+		"candidate multiplier c, queries find at least c * l candidates")
+	flag.UintVar(&f.probes, "probes", 0,
+		"number of low-confidence hash bits each query flips per tree")
+	flag.StringVar(&f.metric, "metric", "cosine",
+		"cosine, jaccard or euclidean")
+	flag.Int64Var(&f.seed, "seed", 1, "seed of the hashers")
+	flag.UintVar(&f.workers, "workers", 1,
+		"number of workers, 0 for GOMAXPROCS")
+	flag.Float64Var(&f.recall, "recall", 0,
+		"tune l, maxk, the multiplier and probes for this recall@k")
+	flag.Uint64Var(&f.memory, "memory", 0,
+		"maximum bytes of memory when tuning, 0 for no limit")
+	flag.DurationVar(&f.latency, "latency", 0,
+		"maximum p99 latency when tuning, 0 for no limit")
+	flag.Parse()
+
+	if err := run(f); err != nil {
+		fmt.Fprintln(os.Stderr, "lshforest-bench:", err)
+		os.Exit(1)
+	}
+}
+
+func run(f flags) error {
+	metric, ok := map[string]uint{"cosine": lshforest.Cosine,
+		"jaccard": lshforest.Jaccard, "euclidean": lshforest.Euclidean}[f.metric]
+	if !ok {
+		return fmt.Errorf("unknown metric %q", f.metric)
+	}
+	if f.data == "" {
+		return fmt.Errorf("-data is required")
+	}
+	vectors, err := readVectors(f.data)
+	if err != nil {
+		return err
+	}
+	var queries *[][]float64
+	if f.queries != "" {
+		if queries, err = readVectors(f.queries); err != nil {
+			return err
+		}
+	} else {
+		n := f.n
+		if n > len(*vectors) {
+			n = len(*vectors)
+		}
+		sample := (*vectors)[:n]
+		queries = &sample
+	}
+	options := []lshforest.Option{lshforest.WithSeed(f.seed),
+		lshforest.WithWorkers(f.workers)}
+
+	if f.recall > 0 {
+		tuning, err := bench.Tune(vectors, queries, bench.Target{
+			Metric: metric, K: f.k, Recall: f.recall, Memory: f.memory,
+			Latency: f.latency, Options: options})
+		fmt.Print(tuning)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("best: -l %d -maxk %d -multiplier %d -probes %d\n",
+			tuning.Best.L, tuning.Best.MaxK, tuning.Best.Multiplier,
+			tuning.Best.Probes)
+		return nil
+	}
+
+	report, err := bench.Run(vectors, queries, bench.Config{L: f.l,
+		MaxK: f.maxK, Metric: metric, K: f.k, Multiplier: f.multiplier,
+		Probes: f.probes, Options: options})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("vectors=%d queries=%d l=%d maxk=%d k=%d multiplier=%d "+
+		"probes=%d metric=%s\n", len(*vectors), len(*queries), f.l, f.maxK,
+		f.k, f.multiplier, f.probes, f.metric)
+	fmt.Println(report)
+	return nil
+}
+
+func readVectors(name string) (*[][]float64, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return bench.ReadVectors(file)
+}
@@ -1,5 +1,5 @@
 module github.com/justinfargnoli/lshforest
 
-go 1.14
+go 1.18
 
 require github.com/gaspiman/cosine_similarity v0.0.0-20150401203709-3a4668276fe3
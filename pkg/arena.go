@@ -0,0 +1,65 @@
+package lshforest
+
+import "github.com/justinfargnoli/lshforest/pkg/hash"
+
+// arenaVectors is the largest number of vectors stored in a chunk of an arena
+const arenaVectors = 64
+
+// arena stores copies of vectors of one dimension back to back in chunks, so
+// each vector doesn't take allocations of its own. The first chunk holds one
+// vector, and each one after holds twice as many as the last up to
+// arenaVectors, so a small arena doesn't reserve room for vectors it may never
+// hold. Each chunk has a block of headers of the same size, the slices to
+// which pointers are returned. It only references the chunk being filled, so
+// a full chunk and its headers are freed once none of its vectors are
+// referenced. The room of deleted and updated vectors isn't reused, so one
+// referenced vector keeps its whole chunk alive. Nothing stored in it is ever
+// overwritten, so a query can read a vector while it's deleted
+type arena[F hash.Float] struct {
+	chunk   []F
+	size    int   // the number of vectors chunk has room for
+	headers [][]F // headers[i] is the slice a pointer to which is returned
+}
+
+// copy stores a copy of vector in the arena
+func (a *arena[F]) copy(vector *[]F) *[]F {
+	n := len(*vector)
+	if cap(a.chunk)-len(a.chunk) < n {
+		a.size *= 2
+		if a.size == 0 {
+			a.size = 1
+		} else if a.size > arenaVectors {
+			a.size = arenaVectors
+		}
+		a.chunk = make([]F, 0, a.size*n)
+		a.headers = make([][]F, 0, a.size)
+	}
+	start := len(a.chunk)
+	a.chunk = append(a.chunk, *vector...)
+	if len(a.headers) == cap(a.headers) {
+		// vectors of no components share the chunk of none
+		a.headers = make([][]F, 0, arenaVectors)
+	}
+	// the capacity is capped so appending to the copy can't overwrite the
+	// next vector
+	a.headers = append(a.headers, a.chunk[start:len(a.chunk):len(a.chunk)])
+	return &a.headers[len(a.headers)-1]
+}
+
+// arenas are the arenas of each kind of dense vector
+type arenas struct {
+	dense   arena[float64]
+	dense32 arena[float32]
+}
+
+// own returns p with its dense vector replaced by a copy. Sparse vectors can't
+// be modified through their methods, so they're kept as is
+func (a *arenas) own(p point) point {
+	switch {
+	case p.dense != nil:
+		return point{dense: a.dense.copy(p.dense)}
+	case p.dense32 != nil:
+		return point{dense32: a.dense32.copy(p.dense32)}
+	}
+	return p
+}
@@ -0,0 +1,81 @@
+package lshforest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArena(t *testing.T) {
+	var a arena[float64]
+	var copies []*[]float64
+	var sizes []int // the number of vectors each chunk has room for
+	for i := 0; i < 2*arenaVectors; i++ {
+		vector := []float64{float64(i), 1}
+		copies = append(copies, a.copy(&vector))
+		vector[0] = -1
+		if len(a.chunk) == 2 {
+			sizes = append(sizes, cap(a.chunk)/2)
+		}
+		if cap(a.headers) != cap(a.chunk)/2 {
+			// a block of headers spanning chunks would keep them all alive
+			t.Fatalf("expected: (%v) | got: (%v)", cap(a.chunk)/2,
+				cap(a.headers))
+		}
+		if i == 2 && &a.chunk[2] != &(*copies[2])[0] {
+			t.Fatal("the vectors of a chunk aren't contiguous")
+		}
+	}
+	for i, vector := range copies {
+		if !reflect.DeepEqual(*vector, []float64{float64(i), 1}) {
+			t.Fatalf("expected: ([%v 1]) | got: (%v)", i, *vector)
+		}
+	}
+	expected := []int{1, 2, 4, 8, 16, 32, 64, 64}
+	if !reflect.DeepEqual(sizes, expected) {
+		t.Fatalf("expected: (%v) | got: (%v)", expected, sizes)
+	}
+	// appending to a copy can't overwrite the next one
+	*copies[1] = append(*copies[1], 5)
+	if (*copies[2])[0] != 2 {
+		t.Fatalf("expected: (2) | got: (%v)", (*copies[2])[0])
+	}
+}
+
+func TestCopyOnInsert(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithZeroCopy()}} {
+		lshforest := NewDefault(2, Euclidean, opts...)
+		exact := NewExact(2, Euclidean)
+		buffer := []float64{1, 2}
+		buffer32 := []float32{3, 4}
+		if err := lshforest.Insert(&buffer, "a"); err != nil {
+			t.Fatal(err)
+		}
+		if err := lshforest.InsertFloat32(&buffer32, "b"); err != nil {
+			t.Fatal(err)
+		}
+		if err := exact.Insert(&buffer, "a"); err != nil {
+			t.Fatal(err)
+		}
+		buffer[0], buffer32[0] = 5, 6
+
+		_, vector, _ := lshforest.Get(0)
+		_, vector32, _ := lshforest.GetFloat32(1)
+		_, exactVector, _ := exact.Get(0)
+		if len(opts) > 0 {
+			// the caller's vectors are kept
+			if vector != &buffer || vector32 != &buffer32 {
+				t.Fatalf("expected: (%p, %p) | got: (%p, %p)", &buffer,
+					&buffer32, vector, vector32)
+			}
+			continue
+		}
+		if (*vector)[0] != 1 || (*vector32)[0] != 3 || (*exactVector)[0] != 1 {
+			t.Fatalf("expected: (1, 3, 1) | got: (%v, %v, %v)", *vector,
+				*vector32, *exactVector)
+		}
+		results, err := lshforest.QueryResults(&[]float64{1, 2}, 1)
+		if err != nil || (*results)[0].Similarity != 1 {
+			t.Fatalf("expected: (1) | got: (%+v, %v)", results, err)
+		}
+	}
+}
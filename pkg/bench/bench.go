@@ -0,0 +1,263 @@
+// Package bench measures the recall and latency of an LSHForest against the
+// exact nearest neighbors found by an lshforest.Exact index
+package bench
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/justinfargnoli/lshforest/pkg"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrEmpty is thrown when Run is given no vectors or no queries
+var ErrEmpty = errors.New("bench: vectors and queries must be non-empty")
+
+// Config is the LSHForest which is benchmarked and the queries run against it
+type Config struct {
+	// L is the number of trees in the LSHForest
+	L uint
+	// MaxK is the maximum number of hash functions of each tree
+	MaxK uint
+	// Metric is lshforest.Cosine, lshforest.Jaccard or lshforest.Euclidean
+	Metric uint
+	// K is the number of neighbors each query asks for
+	K uint
+	// Multiplier is the candidate multiplier c of each query, which is passed
+	// with lshforest.Multiplier
+	Multiplier uint
+	// Probes is the number of probes of each tree of each query, which is
+	// passed with lshforest.Probes
+	Probes uint
+	// Options are passed to lshforest.New
+	Options []lshforest.Option
+}
+
+// Build constructs the LSHForest of c and inserts vectors and values into it
+func (c Config) Build(vectors *[][]float64,
+	values *[]interface{}) (*lshforest.LSHForest[interface{}], error) {
+	if len(*vectors) == 0 {
+		return nil, ErrEmpty
+	}
+	forest := lshforest.New(c.L, c.MaxK, uint(len((*vectors)[0])), c.Metric,
+		c.Options...)
+	if err := forest.InsertAll(vectors, values); err != nil {
+		return nil, err
+	}
+	return forest, nil
+}
+
+// Query returns the values of the K nearest neighbors which forest finds for
+// vector when it's queried as c describes
+func (c Config) Query(forest *lshforest.LSHForest[interface{}],
+	vector *[]float64,
+	opts ...lshforest.QueryOption) (*[]interface{}, error) {
+	opts = append([]lshforest.QueryOption{lshforest.Multiplier(c.Multiplier),
+		lshforest.Probes(c.Probes)}, opts...)
+	return forest.Query(vector, c.K, opts...)
+}
+
+// Report is the result of a benchmark
+type Report struct {
+	// Recall is the fraction of the exact K nearest neighbors of the queries
+	// which the LSHForest returned
+	Recall float64
+	// QPS is the number of queries answered per second
+	QPS float64
+	// P50 and P99 are the 50th and 99th percentile query latencies
+	P50, P99 time.Duration
+	// Candidates is the mean number of candidates examined per query
+	Candidates float64
+	// Build is the time InsertAll took
+	Build time.Duration
+	// Memory is the number of bytes of heap which building the LSHForest
+	// allocated and which are still in use
+	Memory uint64
+}
+
+// String formats the report on a single line
+func (r Report) String() string {
+	return fmt.Sprintf("recall=%.4f qps=%.1f p50=%v p99=%v candidates=%.1f "+
+		"build=%v memory=%dB", r.Recall, r.QPS, r.P50, r.P99, r.Candidates,
+		r.Build, r.Memory)
+}
+
+// Run builds an LSHForest from vectors, queries it with each query vector and
+// compares its results with the exact K nearest neighbors of each query
+func Run(vectors, queries *[][]float64, c Config) (Report, error) {
+	if len(*vectors) == 0 || len(*queries) == 0 {
+		return Report{}, ErrEmpty
+	}
+	truth, err := neighbors(vectors, queries, c.Metric, c.K)
+	if err != nil {
+		return Report{}, err
+	}
+	forest, report, err := build(vectors, c)
+	if err != nil {
+		return Report{}, err
+	}
+	err = measure(forest, queries, truth, c, &report)
+	return report, err
+}
+
+// neighbors returns the values, which are indices into vectors, of the exact
+// k nearest neighbors of each query
+func neighbors(vectors, queries *[][]float64, metric,
+	k uint) ([]*[]interface{}, error) {
+	exact := lshforest.NewExact(uint(len((*vectors)[0])), metric)
+	if err := exact.InsertAll(vectors, indices(len(*vectors))); err != nil {
+		return nil, err
+	}
+	truth := make([]*[]interface{}, len(*queries))
+	for i := range *queries {
+		neighbors, err := exact.Query(&(*queries)[i], k)
+		if err != nil {
+			return nil, err
+		}
+		truth[i] = neighbors
+	}
+	return truth, nil
+}
+
+// indices returns the values 0 to n - 1
+func indices(n int) *[]interface{} {
+	values := make([]interface{}, n)
+	for i := range values {
+		values[i] = i
+	}
+	return &values
+}
+
+// build constructs the LSHForest of c from vectors and reports the time and
+// memory it took
+func build(vectors *[][]float64,
+	c Config) (*lshforest.LSHForest[interface{}], Report, error) {
+	var report Report
+	before := heapInUse()
+	start := time.Now()
+	forest, err := c.Build(vectors, indices(len(*vectors)))
+	if err != nil {
+		return nil, Report{}, err
+	}
+	report.Build = time.Since(start)
+	if after := heapInUse(); after > before {
+		report.Memory = after - before
+	}
+	return forest, report, nil
+}
+
+// measure queries forest with each query and fills in the recall, throughput,
+// latency and candidates of report
+func measure(forest *lshforest.LSHForest[interface{}], queries *[][]float64,
+	truth []*[]interface{}, c Config, report *Report) error {
+	latencies := make([]time.Duration, len(*queries))
+	var found, total, candidates uint
+	var elapsed time.Duration
+	for i := range *queries {
+		var stats lshforest.QueryStats
+		start := time.Now()
+		got, err := c.Query(forest, &(*queries)[i], lshforest.Stats(&stats))
+		latencies[i] = time.Since(start)
+		if err != nil {
+			return err
+		}
+		elapsed += latencies[i]
+		candidates += stats.Candidates
+		found += intersection(truth[i], got)
+		total += uint(len(*truth[i]))
+	}
+
+	if total > 0 {
+		report.Recall = float64(found) / float64(total)
+	}
+	if elapsed > 0 {
+		report.QPS = float64(len(*queries)) / elapsed.Seconds()
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	report.P50 = percentile(latencies, 0.50)
+	report.P99 = percentile(latencies, 0.99)
+	report.Candidates = float64(candidates) / float64(len(*queries))
+	return nil
+}
+
+// intersection returns the number of values in both v1 and v2
+func intersection(v1, v2 *[]interface{}) uint {
+	values := make(map[interface{}]bool, len(*v1))
+	for _, value := range *v1 {
+		values[value] = true
+	}
+	var count uint
+	for _, value := range *v2 {
+		if values[value] {
+			count++
+		}
+	}
+	return count
+}
+
+// percentile returns the pth percentile, with p in [0, 1], of the sorted
+// latencies using the nearest rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
+
+// ReadVectors reads one vector per line from r. The components of a vector
+// are separated by whitespace or commas. Blank lines and lines starting with #
+// are skipped
+func ReadVectors(r io.Reader) (*[][]float64, error) {
+	var vectors [][]float64
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		vector := make([]float64, len(fields))
+		for i, field := range fields {
+			component, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bench: line %d: %v", line, err)
+			}
+			vector[i] = component
+		}
+		if len(vectors) > 0 && len(vector) != len(vectors[0]) {
+			return nil, fmt.Errorf("bench: line %d: expected %d components, "+
+				"got %d", line, len(vectors[0]), len(vector))
+		}
+		vectors = append(vectors, vector)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &vectors, nil
+}
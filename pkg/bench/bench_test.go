@@ -0,0 +1,86 @@
+package bench
+
+import (
+	"github.com/justinfargnoli/lshforest/pkg"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var vectors [][]float64
+	for i := 0; i < 300; i++ {
+		vector := make([]float64, 8)
+		for j := range vector {
+			vector[j] = r.NormFloat64()
+		}
+		vectors = append(vectors, vector)
+	}
+	queries := vectors[:20]
+
+	report, err := Run(&vectors, &queries, Config{L: 8, MaxK: 16,
+		Metric: lshforest.Cosine, K: 1,
+		Options: []lshforest.Option{lshforest.WithSeed(2)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every query is in the dataset, so it's its own nearest neighbor
+	if report.Recall != 1 {
+		t.Fatalf("expected: (1) recall | got: (%v)", report.Recall)
+	}
+	if report.Candidates < 1 || report.QPS <= 0 || report.P50 > report.P99 {
+		t.Fatalf("invalid report: (%v)", report)
+	}
+
+	// querying every element finds every neighbor
+	report, err = Run(&vectors, &queries, Config{L: 2, MaxK: 8,
+		Metric: lshforest.Cosine, K: 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Recall != 1 || report.Candidates != 300 {
+		t.Fatalf("expected: (1) recall of (300) candidates | got: (%v)", report)
+	}
+
+	if _, err := Run(&vectors, &[][]float64{}, Config{}); err != ErrEmpty {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrEmpty, err)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i))
+	}
+	if p := percentile(latencies, 0.5); p != 50 {
+		t.Fatalf("expected: (50) | got: (%v)", p)
+	}
+	if p := percentile(latencies, 0.99); p != 99 {
+		t.Fatalf("expected: (99) | got: (%v)", p)
+	}
+	if p := percentile(latencies[:1], 0.99); p != 1 {
+		t.Fatalf("expected: (1) | got: (%v)", p)
+	}
+}
+
+func TestReadVectors(t *testing.T) {
+	vectors, err := ReadVectors(strings.NewReader(
+		"# comment\n1 2 3\n\n4,5,6\n-1.5\t0\t2e3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]float64{{1, 2, 3}, {4, 5, 6}, {-1.5, 0, 2000}}
+	if !reflect.DeepEqual(*vectors, expected) {
+		t.Fatalf("expected: (%v) | got: (%v)", expected, *vectors)
+	}
+
+	if _, err := ReadVectors(strings.NewReader("1 2\n1 2 3\n")); err == nil {
+		t.Fatal("expected an error for vectors of different dimensions")
+	}
+	if _, err := ReadVectors(strings.NewReader("1 a\n")); err == nil {
+		t.Fatal("expected an error for a component which isn't a number")
+	}
+}
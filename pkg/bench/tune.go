@@ -0,0 +1,156 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"github.com/justinfargnoli/lshforest/pkg"
+	"strings"
+	"time"
+)
+
+// ErrNoConfig is thrown when no configuration Tune tried reaches the target
+// recall within the budget
+var ErrNoConfig = errors.New("bench: no configuration meets the target")
+
+// Target is what Tune searches for: the cheapest configuration with at least
+// Recall recall@K within the Memory and Latency budgets
+type Target struct {
+	// Metric is lshforest.Cosine, lshforest.Jaccard or lshforest.Euclidean
+	Metric uint
+	// K is the number of neighbors each query asks for
+	K uint
+	// Recall is the minimum recall@K
+	Recall float64
+	// Memory is the maximum number of bytes the LSHForest of the sample may
+	// use. 0 means no limit
+	Memory uint64
+	// Latency is the maximum p99 query latency. 0 means no limit
+	Latency time.Duration
+	// Ls, MaxKs, Multipliers and Probes are the values of Config.L,
+	// Config.MaxK, Config.Multiplier and Config.Probes which are tried. Each is
+	// given a default if it's nil
+	Ls, MaxKs, Multipliers, Probes []uint
+	// Options are passed to lshforest.New
+	Options []lshforest.Option
+}
+
+// Trial is a configuration Tune tried and how it performed
+type Trial struct {
+	Config Config
+	Report Report
+}
+
+// Tuning is the result of Tune
+type Tuning struct {
+	// Best is the cheapest configuration which meets the target. It's only
+	// set if Found is true
+	Best  Config
+	Found bool
+	// Trials is every configuration tried, in the order they were tried
+	Trials []Trial
+}
+
+// String formats the trade-off curve which Tune explored as a table
+func (t Tuning) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%4s %5s %4s %6s %8s %10s %12s %12s %12s\n", "l", "maxk",
+		"mult", "probes", "recall", "candidates", "p99", "memory", "")
+	for _, trial := range t.Trials {
+		mark := ""
+		if t.Found && trial.Config.same(t.Best) {
+			mark = "best"
+		}
+		fmt.Fprintf(&b, "%4d %5d %4d %6d %8.4f %10.1f %12v %12d %12s\n",
+			trial.Config.L, trial.Config.MaxK, trial.Config.Multiplier,
+			trial.Config.Probes, trial.Report.Recall, trial.Report.Candidates,
+			trial.Report.P99, trial.Report.Memory, mark)
+	}
+	return b.String()
+}
+
+// Tune searches the (l, maxK, multiplier, probes) space for the cheapest
+// configuration which reaches target.Recall within the budgets of target.
+// sample is indexed and queries are run against it. A configuration is
+// cheaper than another if it examines fewer candidates per query, then if it
+// has fewer trees and hash functions. Best.Build constructs the LSHForest of
+// the configuration found. Tune returns ErrNoConfig, along with every trial,
+// if no configuration meets the target
+func Tune(sample, queries *[][]float64, target Target) (Tuning, error) {
+	if len(*sample) == 0 || len(*queries) == 0 {
+		return Tuning{}, ErrEmpty
+	}
+	ls, maxKs, multipliers, probes := target.Ls, target.MaxKs,
+		target.Multipliers, target.Probes
+	if ls == nil {
+		ls = []uint{2, 5, 10, 20}
+	}
+	if maxKs == nil {
+		maxKs = []uint{10, 20, 30}
+	}
+	if multipliers == nil {
+		multipliers = []uint{0, 2, 5, 10, 20}
+	}
+	if probes == nil {
+		probes = []uint{0, 4}
+	}
+	truth, err := neighbors(sample, queries, target.Metric, target.K)
+	if err != nil {
+		return Tuning{}, err
+	}
+
+	var tuning Tuning
+	var best Report
+	for _, l := range ls {
+		for _, maxK := range maxKs {
+			c := Config{L: l, MaxK: maxK, Metric: target.Metric, K: target.K,
+				Options: target.Options}
+			forest, built, err := build(sample, c)
+			if err != nil {
+				return Tuning{}, err
+			}
+			for _, multiplier := range multipliers {
+				for _, n := range probes {
+					c.Multiplier, c.Probes = multiplier, n
+					report := built
+					if err := measure(forest, queries, truth, c,
+						&report); err != nil {
+						return Tuning{}, err
+					}
+					tuning.Trials = append(tuning.Trials,
+						Trial{Config: c, Report: report})
+					if target.meets(report) && (!tuning.Found ||
+						cheaper(c, report, tuning.Best, best)) {
+						tuning.Best, best, tuning.Found = c, report, true
+					}
+				}
+			}
+		}
+	}
+	if !tuning.Found {
+		return tuning, ErrNoConfig
+	}
+	return tuning, nil
+}
+
+func (t Target) meets(report Report) bool {
+	return report.Recall >= t.Recall &&
+		(t.Memory == 0 || report.Memory <= t.Memory) &&
+		(t.Latency == 0 || report.P99 <= t.Latency)
+}
+
+// same returns whether c and other have the same parameters, ignoring their
+// options
+func (c Config) same(other Config) bool {
+	return c.L == other.L && c.MaxK == other.MaxK && c.Metric == other.Metric &&
+		c.K == other.K && c.Multiplier == other.Multiplier &&
+		c.Probes == other.Probes
+}
+
+// cheaper returns whether c1, which performed as r1, is cheaper than c2, which
+// performed as r2
+func cheaper(c1 Config, r1 Report, c2 Config, r2 Report) bool {
+	if r1.Candidates != r2.Candidates {
+		return r1.Candidates < r2.Candidates
+	}
+	return c1.L*c1.MaxK < c2.L*c2.MaxK
+}
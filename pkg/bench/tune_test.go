@@ -0,0 +1,94 @@
+package bench
+
+import (
+	"github.com/justinfargnoli/lshforest/pkg"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// clustered returns n vectors of dimension dim around a few centers, and a
+// perturbed copy of the first queries of them
+func clustered(n, queries, dim int) (*[][]float64, *[][]float64) {
+	r := rand.New(rand.NewSource(1))
+	centers := make([][]float64, 8)
+	for i := range centers {
+		centers[i] = make([]float64, dim)
+		for j := range centers[i] {
+			centers[i][j] = r.NormFloat64()
+		}
+	}
+	var vectors, perturbed [][]float64
+	for i := 0; i < n; i++ {
+		vector := make([]float64, dim)
+		for j := range vector {
+			vector[j] = centers[i%len(centers)][j] + 0.3*r.NormFloat64()
+		}
+		vectors = append(vectors, vector)
+	}
+	for i := 0; i < queries; i++ {
+		vector := make([]float64, dim)
+		for j := range vector {
+			vector[j] = vectors[i][j] + 0.05*r.NormFloat64()
+		}
+		perturbed = append(perturbed, vector)
+	}
+	return &vectors, &perturbed
+}
+
+func TestTune(t *testing.T) {
+	sample, queries := clustered(400, 20, 8)
+	target := Target{Metric: lshforest.Cosine, K: 5, Recall: 0.6,
+		Ls: []uint{2, 8}, MaxKs: []uint{8, 16}, Multipliers: []uint{0, 4},
+		Probes:  []uint{0, 4},
+		Options: []lshforest.Option{lshforest.WithSeed(3)}}
+	tuning, err := Tune(sample, queries, target)
+	if err != nil {
+		t.Fatalf("%v\n%v", err, tuning)
+	}
+	if len(tuning.Trials) != 16 {
+		t.Fatalf("expected: (16) trials | got: (%v)", len(tuning.Trials))
+	}
+	var best Report
+	for _, trial := range tuning.Trials {
+		if trial.Config.same(tuning.Best) {
+			best = trial.Report
+		}
+	}
+	if best.Recall < target.Recall {
+		t.Fatalf("best has recall (%v)\n%v", best.Recall, tuning)
+	}
+	for _, trial := range tuning.Trials {
+		if target.meets(trial.Report) &&
+			trial.Report.Candidates < best.Candidates {
+			t.Fatalf("trial (%v) is cheaper than the best\n%v", trial, tuning)
+		}
+	}
+	if !strings.Contains(tuning.String(), "best") {
+		t.Fatalf("report doesn't mark the best configuration:\n%v", tuning)
+	}
+
+	forest, err := tuning.Best.Build(sample, indices(len(*sample)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := tuning.Best.Query(forest, &(*queries)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint(len(*values)) != target.K {
+		t.Fatalf("expected: (%v) values | got: (%v)", target.K, *values)
+	}
+}
+
+func TestTuneNoConfig(t *testing.T) {
+	sample, queries := clustered(100, 5, 4)
+	tuning, err := Tune(sample, queries, Target{Metric: lshforest.Cosine,
+		K: 3, Recall: 1.1, Ls: []uint{2}, MaxKs: []uint{8, 16}})
+	if err != ErrNoConfig {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrNoConfig, err)
+	}
+	if tuning.Found || len(tuning.Trials) != 20 {
+		t.Fatalf("expected: (20) trials and no best | got: (%v)", tuning)
+	}
+}
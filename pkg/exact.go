@@ -0,0 +1,266 @@
+package lshforest
+
+import (
+	"context"
+	"errors"
+	"github.com/justinfargnoli/lshforest/pkg/hash"
+	"github.com/justinfargnoli/lshforest/pkg/lshtree"
+	"math"
+	"sort"
+)
+
+// Exact is a brute-force index with the same Insert and Query methods as
+// LSHForest. Every query compares the query vector against every element, so
+// its results are exact. It suits small datasets and measuring the recall of
+// an LSHForest. It always stores copies of the vectors inserted into it, as an
+// LSHForest does unless it's constructed WithZeroCopy
+type Exact[V any] struct {
+	similarity func(point, point) float64
+	metric     uint
+	vecDim     uint
+	// elements holds every element in id order, so queries scan it as is and
+	// equally similar elements are ranked the same way by every query
+	elements []lshtree.Element[V]
+	arenas   arenas
+	nextID   uint
+}
+
+// NewExact constructs an Exact index for the given similarity metric and
+// dimension of the input vectors
+func NewExact(dim, metric uint) *Exact[interface{}] {
+	return NewExactTyped[interface{}](dim, metric)
+}
+
+// NewExactTyped constructs an Exact index, as NewExact does, whose values
+// have type V
+func NewExactTyped[V any](dim, metric uint) *Exact[V] {
+	switch metric {
+	case Cosine, Jaccard, Euclidean:
+	default:
+		panic("lshforest invalid metric")
+	}
+	return &Exact[V]{similarity: similarity(metric), metric: metric, vecDim: dim}
+}
+
+// Len returns the number of elements in the index
+func (e *Exact[V]) Len() uint {
+	return uint(len(e.elements))
+}
+
+// InsertAll adds each vector and value to the index. Nothing is inserted if
+// any vector is invalid. The ids of the elements are consecutive
+func (e *Exact[V]) InsertAll(vectors *[][]float64, values *[]V) error {
+	_, err := e.InsertAllID(vectors, values)
+	return err
+}
+
+// InsertAllID adds each vector and value to the index, as
+// LSHForest.InsertAllID does, and returns the id of the first element
+func (e *Exact[V]) InsertAllID(vectors *[][]float64,
+	values *[]V) (first uint, err error) {
+	if len(*vectors) != len(*values) {
+		return 0, errors.New("len(*vectors) != len(*values)")
+	}
+	for i := range *vectors {
+		if err := e.checkVector(&(*vectors)[i]); err != nil {
+			return 0, inBatch(err, i)
+		}
+	}
+	first, err = reserveIDs(&e.nextID, uint(len(*vectors)))
+	if err != nil {
+		return 0, err
+	}
+	for i := range *vectors {
+		e.put(first+uint(i), point{dense: &(*vectors)[i]}, (*values)[i])
+	}
+	return first, nil
+}
+
+// Insert puts the vector into the index. Elements are given ids in insertion
+// order, as they are by LSHForest.Insert
+func (e *Exact[V]) Insert(vector *[]float64, value V) error {
+	_, err := e.InsertID(vector, value)
+	return err
+}
+
+// InsertID puts the vector into the index, as LSHForest.InsertID does
+func (e *Exact[V]) InsertID(vector *[]float64, value V) (uint, error) {
+	return e.insertNext(point{dense: vector}, value)
+}
+
+// insertNext puts the vector into the index with the next id and returns the
+// id
+func (e *Exact[V]) insertNext(p point, value V) (uint, error) {
+	if err := checkPoint(p, e.metric, e.vecDim); err != nil {
+		return 0, err
+	}
+	id, err := reserveIDs(&e.nextID, 1)
+	if err != nil {
+		return 0, err
+	}
+	e.put(id, p, value)
+	return id, nil
+}
+
+// InsertWithID puts the vector into the index with the given id, as
+// LSHForest.InsertWithID does
+func (e *Exact[V]) InsertWithID(id uint, vector *[]float64,
+	value V) error {
+	if err := e.checkVector(vector); err != nil {
+		return err
+	}
+	if id == math.MaxUint {
+		return ErrIDOverflow
+	}
+	if e.Contains(id) {
+		return ErrIDExists
+	}
+	e.put(id, point{dense: vector}, value)
+	if id >= e.nextID {
+		e.nextID = id + 1
+	}
+	return nil
+}
+
+// put stores a copy of the vector, as LSHForest does, along with the value
+// under the given id, replacing the element with the id if there is one
+func (e *Exact[V]) put(id uint, p point, value V) {
+	element := newEntry(e.arenas.own(p), value).element(id, hash.Signature{})
+	i, ok := e.search(id)
+	if !ok {
+		e.elements = append(e.elements, lshtree.Element[V]{})
+		copy(e.elements[i+1:], e.elements[i:])
+	}
+	e.elements[i] = element
+}
+
+// search returns the index of the element with the given id in e.elements,
+// or the index it would be inserted at, and whether it's there
+func (e *Exact[V]) search(id uint) (int, bool) {
+	i := sort.Search(len(e.elements), func(i int) bool {
+		return e.elements[i].ID >= id
+	})
+	return i, i < len(e.elements) && e.elements[i].ID == id
+}
+
+// Get returns the value and vector of the element with the given id. The
+// vector is the index's copy, which shouldn't be modified
+func (e *Exact[V]) Get(id uint) (V, *[]float64, error) {
+	i, ok := e.search(id)
+	if !ok {
+		var zero V
+		return zero, nil, ErrNotFound
+	}
+	return e.elements[i].Value, e.elements[i].Vector, nil
+}
+
+// Contains returns whether an element has the given id
+func (e *Exact[V]) Contains(id uint) bool {
+	_, ok := e.search(id)
+	return ok
+}
+
+// IDs returns the id of every element in the index in ascending order
+func (e *Exact[V]) IDs() []uint {
+	ids := make([]uint, len(e.elements))
+	for i, element := range e.elements {
+		ids[i] = element.ID
+	}
+	return ids
+}
+
+// Delete removes the element with the given id from the index
+func (e *Exact[V]) Delete(id uint) error {
+	i, ok := e.search(id)
+	if !ok {
+		return ErrNotFound
+	}
+	last := len(e.elements) - 1
+	copy(e.elements[i:], e.elements[i+1:])
+	// the removed element's vector mustn't be kept alive past the end
+	e.elements[last] = lshtree.Element[V]{}
+	e.elements = e.elements[:last]
+	return nil
+}
+
+// Update replaces the vector and value of the element with the given id
+func (e *Exact[V]) Update(id uint, vector *[]float64, value V) error {
+	if err := e.checkVector(vector); err != nil {
+		return err
+	}
+	if !e.Contains(id) {
+		return ErrNotFound
+	}
+	e.put(id, point{dense: vector}, value)
+	return nil
+}
+
+func (e *Exact[V]) checkVector(vector *[]float64) error {
+	return checkVector(vector, e.metric, e.vecDim)
+}
+
+func (e *Exact[V]) checkQuery(query point) error {
+	if err := checkPoint(query, e.metric, e.vecDim); err != nil {
+		return err
+	}
+	if len(e.elements) == 0 {
+		return ErrEmptyForest
+	}
+	return nil
+}
+
+// Query returns a list of the m values most similar to the query vector,
+// sorted by similarity
+func (e *Exact[V]) Query(vector *[]float64, m uint,
+	opts ...QueryOption) (*[]V, error) {
+	results, err := e.QueryResults(vector, m, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return resultValues(results), nil
+}
+
+// QueryIDs returns a list of the ids of the m elements most similar to the
+// query vector, sorted by similarity
+func (e *Exact[V]) QueryIDs(vector *[]float64, m uint,
+	opts ...QueryOption) (*[]uint, error) {
+	results, err := e.QueryResults(vector, m, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return resultIDs(results), nil
+}
+
+// QueryResults returns a list of the m results most similar to the query
+// vector, sorted by similarity. Result.Trees is always 0 and probes are
+// ignored
+func (e *Exact[V]) QueryResults(vector *[]float64, m uint,
+	opts ...QueryOption) (*[]Result[V], error) {
+	return e.queryResults(point{dense: vector}, m, opts)
+}
+
+func (e *Exact[V]) queryResults(query point, m uint,
+	opts []QueryOption) (*[]Result[V], error) {
+	if err := e.checkQuery(query); err != nil {
+		return nil, err
+	}
+	top, similarities, scored, _ := topElements(context.Background(),
+		&e.elements, score[V](e.similarity, query), m, math.Inf(-1))
+	newQueryConfig(queryConfig{}, opts).record(scored, false)
+	return newResults(top, similarities, nil), nil
+}
+
+// QueryRadius returns a list of results, sorted by similarity to the query
+// vector, of every element with a similarity of at least minSimilarity
+func (e *Exact[V]) QueryRadius(vector *[]float64, minSimilarity float64,
+	opts ...QueryOption) (*[]Result[V], error) {
+	query := point{dense: vector}
+	if err := e.checkQuery(query); err != nil {
+		return nil, err
+	}
+	top, similarities, scored, _ := topElements(context.Background(),
+		&e.elements, score[V](e.similarity, query), uint(len(e.elements)),
+		minSimilarity)
+	newQueryConfig(queryConfig{}, opts).record(scored, false)
+	return newResults(top, similarities, nil), nil
+}
@@ -0,0 +1,290 @@
+package lshforest
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestExact(t *testing.T) {
+	vectors := [][]float64{{1, 0, 0}, {1, 1, 0}, {0, 1, 0}, {0, 0, 1}}
+	values := []interface{}{0, 1, 2, 3}
+	exact := NewExact(3, Cosine)
+	if err := exact.InsertAll(&vectors, &values); err != nil {
+		t.Fatal(err)
+	}
+	query := []float64{1, 0.1, 0}
+	got, err := exact.Query(&query, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{0, 1, 2}; !reflect.DeepEqual(*got, expected) {
+		t.Fatalf("expected: (%v) | got: (%v)", expected, *got)
+	}
+
+	if err := exact.Delete(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := exact.Delete(0); err != ErrNotFound {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrNotFound, err)
+	}
+	vector := []float64{0, 0, 2}
+	if err := exact.Update(2, &vector, -2); err != nil {
+		t.Fatal(err)
+	}
+	results, err := exact.QueryRadius(&vector, 0.99)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*results) != 2 || (*results)[0].Value != -2 ||
+		(*results)[1].Value != 3 {
+		t.Fatalf("expected: ([-2 3]) | got: (%v)", *results)
+	}
+	if exact.Len() != 3 {
+		t.Fatalf("expected: (3) | got: (%v)", exact.Len())
+	}
+}
+
+func TestExactInvalid(t *testing.T) {
+	exact := NewExact(2, Jaccard)
+	vector := []float64{1, 0}
+	if _, err := exact.Query(&vector, 1); err != ErrEmptyForest {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrEmptyForest, err)
+	}
+	zero := []float64{0, 0}
+	if err := exact.Insert(&zero, 0); err != ErrNonZero {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrNonZero, err)
+	}
+	long := []float64{1, 0, 1}
+	if err := exact.Insert(&long, 0); err != ErrEqDim {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrEqDim, err)
+	}
+	nan := []float64{1, math.NaN()}
+	if err := exact.Insert(&nan, 0); err != (NonFiniteError{Index: 1}) {
+		t.Fatalf("expected: (%v) | got: (%v)", NonFiniteError{Index: 1}, err)
+	}
+	if err := exact.Update(5, &vector, 0); err != ErrNotFound {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrNotFound, err)
+	}
+}
+
+func TestNewExactPanicInvalidMetric(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.FailNow()
+		}
+	}()
+	_ = NewExact(3, 100)
+}
+
+// recall returns the fraction of the m nearest neighbors of each query which
+// lshforest finds
+func recall(t *testing.T, lshforest *LSHForest[interface{}], exact *Exact[interface{}],
+	queries [][]float64, m uint) float64 {
+	var found, total int
+	for i := range queries {
+		expected, err := exact.Query(&queries[i], m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := lshforest.Query(&queries[i], m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := make(map[interface{}]bool)
+		for _, value := range *got {
+			values[value] = true
+		}
+		for _, value := range *expected {
+			if values[value] {
+				found++
+			}
+			total++
+		}
+	}
+	return float64(found) / float64(total)
+}
+
+func TestRecall(t *testing.T) {
+	const dim = 16
+	r := rand.New(rand.NewSource(3))
+	vectors, values := randomVectors(r, 1000, dim)
+	// each query is a perturbed copy of an element
+	var queries [][]float64
+	for i := 0; i < 50; i++ {
+		query := randomVector(r, dim)
+		for j := range query {
+			query[j] = vectors[i][j] + 0.1*query[j]
+		}
+		queries = append(queries, query)
+	}
+
+	for _, metric := range []uint{Cosine, Euclidean} {
+		lshforest := New(10, 20, dim, metric, WithSeed(5))
+		exact := NewExact(dim, metric)
+		if err := lshforest.InsertAll(&vectors, &values); err != nil {
+			t.Fatal(err)
+		}
+		if err := exact.InsertAll(&vectors, &values); err != nil {
+			t.Fatal(err)
+		}
+		if r := recall(t, lshforest, exact, queries, 1); r < 0.6 {
+			t.Fatalf("metric (%v) has recall (%v)", metric, r)
+		}
+		if r := recall(t, lshforest, exact, queries, 1000); r != 1 {
+			t.Fatalf("metric (%v) has recall (%v) querying every element",
+				metric, r)
+		}
+	}
+}
+
+func TestMultiplier(t *testing.T) {
+	const dim, l = 16, 5
+	r := rand.New(rand.NewSource(4))
+	vectors, values := randomVectors(r, 1000, dim)
+	queries := vectors[:50]
+	exact := NewExact(dim, Cosine)
+	if err := exact.InsertAll(&vectors, &values); err != nil {
+		t.Fatal(err)
+	}
+	lshforest := New(l, 20, dim, Cosine, WithSeed(5))
+	widened := New(l, 20, dim, Cosine, WithSeed(5), WithMultiplier(20))
+	for _, f := range []*LSHForest[interface{}]{lshforest, widened} {
+		if err := f.InsertAll(&vectors, &values); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range []uint{0, 4, 20} {
+		var stats QueryStats
+		if _, err := lshforest.Query(&queries[0], 5, Multiplier(c),
+			Stats(&stats)); err != nil {
+			t.Fatal(err)
+		}
+		// an element is collected once from each tree it's found in, so
+		// fewer distinct candidates can reach c * l
+		if stats.Collected < c*l || stats.Candidates*l < stats.Collected {
+			t.Fatalf("expected: at least (%v) collected | got: (%+v)", c*l,
+				stats)
+		}
+	}
+
+	base := recall(t, lshforest, exact, queries, 5)
+	if r := recall(t, widened, exact, queries, 5); r <= base {
+		t.Fatalf("expected: recall above (%v) | got: (%v)", base, r)
+	}
+}
+
+func TestProbes(t *testing.T) {
+	const dim, l = 16, 2
+	r := rand.New(rand.NewSource(6))
+	vectors, values := randomVectors(r, 1000, dim)
+	queries := vectors[:50]
+
+	for _, metric := range []uint{Cosine, Euclidean} {
+		exact := NewExact(dim, metric)
+		if err := exact.InsertAll(&vectors, &values); err != nil {
+			t.Fatal(err)
+		}
+		lshforest := New(l, 20, dim, metric, WithSeed(5))
+		probed := New(l, 20, dim, metric, WithSeed(5), WithProbes(8))
+		for _, f := range []*LSHForest[interface{}]{lshforest, probed} {
+			if err := f.InsertAll(&vectors, &values); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var stats QueryStats
+		if _, err := probed.Query(&queries[0], 5, Stats(&stats)); err != nil {
+			t.Fatal(err)
+		}
+		if stats.Probes == 0 || stats.Probes > 8*l {
+			t.Fatalf("metric (%v) expected: (1) to (%v) probes | got: (%v)",
+				metric, 8*l, stats.Probes)
+		}
+		if _, err := probed.Query(&queries[0], 5, Probes(0),
+			Stats(&stats)); err != nil {
+			t.Fatal(err)
+		}
+		if stats.Probes != 0 {
+			t.Fatalf("expected: (0) probes | got: (%v)", stats.Probes)
+		}
+
+		base := recall(t, lshforest, exact, queries, 5)
+		if r := recall(t, probed, exact, queries, 5); r <= base {
+			t.Fatalf("metric (%v) expected: recall above (%v) | got: (%v)",
+				metric, base, r)
+		}
+	}
+}
+
+func TestExactIDs(t *testing.T) {
+	vectors := [][]float64{{1, 0, 0}, {1, 1, 0}, {0, 1, 0}}
+	exact := NewExact(3, Cosine)
+	if err := exact.InsertWithID(3, &vectors[0], "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := exact.InsertWithID(3, &vectors[1], "b"); err != ErrIDExists {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrIDExists, err)
+	}
+	if err := exact.Insert(&vectors[1], "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := exact.InsertWithID(1, &vectors[2], "c"); err != nil {
+		t.Fatal(err)
+	}
+	if ids := exact.IDs(); !reflect.DeepEqual(ids, []uint{1, 3, 4}) {
+		t.Fatalf("expected: ([1 3 4]) | got: (%v)", ids)
+	}
+	if value, _, err := exact.Get(4); err != nil || value != "b" {
+		t.Fatalf("expected: (b) | got: (%v) (%v)", value, err)
+	}
+	if !exact.Contains(1) || exact.Contains(0) {
+		t.Fatalf("unexpected ids: (%v)", exact.IDs())
+	}
+	ids, err := exact.QueryIDs(&vectors[2], 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []uint{1, 4, 3}; !reflect.DeepEqual(*ids, expected) {
+		t.Fatalf("expected: (%v) | got: (%v)", expected, *ids)
+	}
+
+	if err := exact.Delete(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := exact.Update(1, &vectors[0], "d"); err != nil {
+		t.Fatal(err)
+	}
+	if err := exact.InsertWithID(2, &vectors[2], "e"); err != nil {
+		t.Fatal(err)
+	}
+	if ids := exact.IDs(); !reflect.DeepEqual(ids, []uint{1, 2, 4}) {
+		t.Fatalf("expected: ([1 2 4]) | got: (%v)", ids)
+	}
+	values, err := exact.Query(&vectors[0], 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{"d", "b", "e"}; !reflect.DeepEqual(*values,
+		expected) {
+		t.Fatalf("expected: (%v) | got: (%v)", expected, *values)
+	}
+}
+
+func TestExactTyped(t *testing.T) {
+	vectors := [][]float64{{1, 0}, {0, 1}}
+	values := []float64{0.5, 1.5}
+	exact := NewExactTyped[float64](2, Euclidean)
+	if err := exact.InsertAll(&vectors, &values); err != nil {
+		t.Fatal(err)
+	}
+	got, err := exact.Query(&[]float64{0, 2}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []float64{1.5, 0.5}; !reflect.DeepEqual(*got, expected) {
+		t.Fatalf("expected: (%v) | got: (%v)", expected, *got)
+	}
+}
@@ -0,0 +1,113 @@
+package lshforest
+
+import (
+	"context"
+	"errors"
+)
+
+// InsertFloat32 puts the float32 vector into the LSHForest, as Insert does. The
+// vector is stored, hashed and re-ranked as is, without widening it to float64
+func (f *LSHForest[V]) InsertFloat32(vector *[]float32, value V) error {
+	_, err := f.InsertFloat32ID(vector, value)
+	return err
+}
+
+// InsertFloat32ID puts the float32 vector into the LSHForest, as InsertFloat32
+// does, and returns the id it's given
+func (f *LSHForest[V]) InsertFloat32ID(vector *[]float32,
+	value V) (uint, error) {
+	return f.insertNext(newEntry(point{dense32: vector}, value))
+}
+
+// InsertAllFloat32 adds each float32 vector and value to the LSH Forest, as
+// InsertAll does
+func (f *LSHForest[V]) InsertAllFloat32(vectors *[][]float32,
+	values *[]V) error {
+	_, err := f.InsertAllFloat32ID(vectors, values)
+	return err
+}
+
+// InsertAllFloat32ID adds each float32 vector and value to the LSH Forest, as
+// InsertAllID does, and returns the id of the first element
+func (f *LSHForest[V]) InsertAllFloat32ID(vectors *[][]float32,
+	values *[]V) (first uint, err error) {
+	if len(*vectors) != len(*values) {
+		return 0, errors.New("len(*vectors) != len(*values)")
+	}
+	entries := make([]entry[V], len(*vectors))
+	for i := range *vectors {
+		entries[i] = newEntry(point{dense32: &(*vectors)[i]}, (*values)[i])
+	}
+	return f.insertAll(entries)
+}
+
+// GetFloat32 returns the value and float32 vector of the element with the
+// given id. The vector is nil unless the element was inserted with a float32
+// vector. It shouldn't be modified, as Get's shouldn't
+func (f *LSHForest[V]) GetFloat32(id uint) (V, *[]float32, error) {
+	f.lock()
+	defer f.unlock()
+	entry, ok := f.entries[id]
+	if !ok {
+		var zero V
+		return zero, nil, ErrNotFound
+	}
+	return entry.Value, entry.Vector32, nil
+}
+
+// QueryFloat32 returns a list of the m values most similar to the float32
+// query vector, as Query does
+func (f *LSHForest[V]) QueryFloat32(vector *[]float32, m uint,
+	opts ...QueryOption) (*[]V, error) {
+	results, err := f.QueryResultsFloat32(vector, m, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return resultValues(results), nil
+}
+
+// QueryResultsFloat32 returns a list of results sorted by similarity to the
+// float32 query vector, as QueryResults does
+func (f *LSHForest[V]) QueryResultsFloat32(vector *[]float32, m uint,
+	opts ...QueryOption) (*[]Result[V], error) {
+	return f.QueryResultsFloat32Context(context.Background(), vector, m,
+		opts...)
+}
+
+// QueryResultsFloat32Context is QueryResultsFloat32 which stops once ctx is
+// done, as QueryResultsContext does
+func (f *LSHForest[V]) QueryResultsFloat32Context(ctx context.Context,
+	vector *[]float32, m uint, opts ...QueryOption) (*[]Result[V], error) {
+	return f.queryResults(ctx, point{dense32: vector}, m, opts)
+}
+
+// InsertFloat32 puts the float32 vector into the index, as
+// LSHForest.InsertFloat32 does
+func (e *Exact[V]) InsertFloat32(vector *[]float32, value V) error {
+	_, err := e.InsertFloat32ID(vector, value)
+	return err
+}
+
+// InsertFloat32ID puts the float32 vector into the index, as
+// LSHForest.InsertFloat32ID does
+func (e *Exact[V]) InsertFloat32ID(vector *[]float32, value V) (uint, error) {
+	return e.insertNext(point{dense32: vector}, value)
+}
+
+// QueryFloat32 returns a list of the m values most similar to the float32
+// query vector, sorted by similarity
+func (e *Exact[V]) QueryFloat32(vector *[]float32, m uint,
+	opts ...QueryOption) (*[]V, error) {
+	results, err := e.QueryResultsFloat32(vector, m, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return resultValues(results), nil
+}
+
+// QueryResultsFloat32 returns a list of the m results most similar to the
+// float32 query vector, sorted by similarity
+func (e *Exact[V]) QueryResultsFloat32(vector *[]float32, m uint,
+	opts ...QueryOption) (*[]Result[V], error) {
+	return e.queryResults(point{dense32: vector}, m, opts)
+}
@@ -0,0 +1,150 @@
+package lshforest
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// float32Vectors returns n random float32 vectors of dimension dim along with
+// their widened float64 forms
+func float32Vectors(r *rand.Rand, n, dim int) ([][]float32, [][]float64) {
+	var vectors [][]float32
+	var wide [][]float64
+	for i := 0; i < n; i++ {
+		vector := make([]float32, dim)
+		widened := make([]float64, dim)
+		for j := range vector {
+			vector[j] = float32(r.NormFloat64())
+			widened[j] = float64(vector[j])
+		}
+		vectors = append(vectors, vector)
+		wide = append(wide, widened)
+	}
+	return vectors, wide
+}
+
+func TestFloat32(t *testing.T) {
+	const dim = 50
+	r := rand.New(rand.NewSource(10))
+	vectors, wide := float32Vectors(r, 200, dim)
+	var values []interface{}
+	for i := range vectors {
+		values = append(values, i)
+	}
+
+	for _, metric := range []uint{Cosine, Jaccard, Euclidean} {
+		// every other element of mixed is float64
+		lshforest := New(5, 10, dim, metric, WithSeed(3))
+		float32Forest := New(5, 10, dim, metric, WithSeed(3))
+		mixed := New(5, 10, dim, metric, WithSeed(3))
+		if err := lshforest.InsertAll(&wide, &values); err != nil {
+			t.Fatal(err)
+		}
+		if err := float32Forest.InsertAllFloat32(&vectors, &values); err != nil {
+			t.Fatal(err)
+		}
+		for i := range vectors {
+			var err error
+			if i%2 == 0 {
+				err = mixed.InsertFloat32(&vectors[i], i)
+			} else {
+				err = mixed.Insert(&wide[i], i)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for i := 0; i < 20; i++ {
+			expected, err := lshforest.QueryResults(&wide[i], 5, Probes(2))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, forest := range []*LSHForest[interface{}]{float32Forest,
+				mixed} {
+				got, err := forest.QueryResultsFloat32(&vectors[i], 5, Probes(2))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(*got) != len(*expected) {
+					t.Fatalf("metric (%v) expected: (%v) results | got: (%v)",
+						metric, len(*expected), len(*got))
+				}
+				for j := range *expected {
+					e, g := (*expected)[j], (*got)[j]
+					if g.ID != e.ID ||
+						math.Abs(g.Similarity-e.Similarity) > 1e-12 ||
+						(g.Vector32 != nil) == (g.Vector != nil) {
+						t.Fatalf("metric (%v) expected: (%+v) | got: (%+v)",
+							metric, e, g)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestFloat32Invalid(t *testing.T) {
+	lshforest := NewDefault(3, Cosine)
+	if err := lshforest.InsertFloat32(&[]float32{1, 2}, "a"); err != ErrEqDim {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrEqDim, err)
+	}
+	if err := lshforest.InsertFloat32(&[]float32{0, 0, 0},
+		"a"); err != ErrNonZero {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrNonZero, err)
+	}
+
+	vector := []float32{1, 2, 3}
+	if err := lshforest.InsertFloat32(&vector, "a"); err != nil {
+		t.Fatal(err)
+	}
+	value, got, err := lshforest.GetFloat32(0)
+	if err != nil || value != "a" || !reflect.DeepEqual(*got, vector) {
+		t.Fatalf("expected: (a, %v) | got: (%v, %v, %v)", vector, value, got,
+			err)
+	}
+	if _, dense, _ := lshforest.Get(0); dense != nil {
+		t.Fatalf("expected: (nil) | got: (%v)", dense)
+	}
+	values, err := lshforest.QueryFloat32(&vector, 1)
+	if err != nil || (*values)[0] != "a" {
+		t.Fatalf("expected: ([a]) | got: (%v, %v)", values, err)
+	}
+}
+
+func TestExactFloat32(t *testing.T) {
+	const dim = 20
+	r := rand.New(rand.NewSource(11))
+	vectors, wide := float32Vectors(r, 50, dim)
+	var values []interface{}
+	for i := range wide {
+		values = append(values, i)
+	}
+	exact := NewExact(dim, Euclidean)
+	if err := exact.InsertAll(&wide, &values); err != nil {
+		t.Fatal(err)
+	}
+	float32Exact := NewExact(dim, Euclidean)
+	for i := range vectors {
+		if err := float32Exact.InsertFloat32(&vectors[i], i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range vectors {
+		expected, err := exact.Query(&wide[i], 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := float32Exact.QueryFloat32(&vectors[i], 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := range *expected {
+			if (*got)[j] != (*expected)[j] {
+				t.Fatalf("expected: (%v) | got: (%v)", *expected, *got)
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package hash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var errEncoding = errors.New("hash: invalid encoding")
+
+// MarshalBinary encodes the number and dimension of the hyperplanes of the
+// simhash.Online builder along with the seed they're derived from
+func (o Online) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	putUvarint(&buf, uint64(o.Bits()))
+	putUvarint(&buf, uint64(o.Dim()))
+	binary.Write(&buf, binary.LittleEndian, o.hyperplanes.seed)
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes hyperplanes encoded by MarshalBinary into the
+// simhash.Online builder
+func (o *Online) UnmarshalBinary(data []byte) error {
+	count, dim, seed, buf, err := readHyperplanes(data)
+	if err != nil || buf.Len() != 0 {
+		return errEncoding
+	}
+	o.hyperplanes = newHyperplanes(seed, count, dim)
+	return nil
+}
+
+// readHyperplanes reads the number, dimension and seed of hyperplanes which
+// MarshalBinary encoded at the start of data. The rest of data is left in buf
+func readHyperplanes(data []byte) (count, dim uint, seed int64,
+	buf *bytes.Reader, err error) {
+	buf = bytes.NewReader(data)
+	var header [2]uint64
+	for i := range header {
+		if header[i], err = binary.ReadUvarint(buf); err != nil {
+			return 0, 0, 0, nil, err
+		}
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &seed); err != nil {
+		return 0, 0, 0, nil, err
+	}
+	return uint(header[0]), uint(header[1]), seed, buf, nil
+}
+
+// MarshalBinary encodes the permutations of the minhash.Minhash builder
+func (m Minhash) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	putUvarint(&buf, uint64(len(*m.permutations)))
+	for _, permutation := range *m.permutations {
+		binary.Write(&buf, binary.LittleEndian, permutation)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes permutations encoded by MarshalBinary into the
+// minhash.Minhash builder
+func (m *Minhash) UnmarshalBinary(data []byte) error {
+	buf := bytes.NewReader(data)
+	count, err := binary.ReadUvarint(buf)
+	if err != nil || !fits(count, 16, buf.Len()) {
+		return errEncoding
+	}
+	permutations := make([]Permutation, count)
+	binary.Read(buf, binary.LittleEndian, permutations)
+	m.permutations = &permutations
+	return nil
+}
+
+// fits returns whether count items of size bytes each take up exactly n bytes.
+// It's checked by division first, so a corrupt count can't overflow it. Items
+// of no size only fit if there are none, as their count can't be checked
+func fits(count, size uint64, n int) bool {
+	if size == 0 {
+		return count == 0 && n == 0
+	}
+	return count <= uint64(n)/size && count*size == uint64(n)
+}
+
+func putUvarint(buf *bytes.Buffer, x uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], x)])
+}
+
+// MarshalBinary encodes the number and dimension of the projections of the
+// pstable.PStable builder, the seed they're derived from and its width
+func (p PStable) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	putUvarint(&buf, uint64(p.Bits()))
+	putUvarint(&buf, uint64(p.Dim()))
+	binary.Write(&buf, binary.LittleEndian, p.hyperplanes.seed)
+	binary.Write(&buf, binary.LittleEndian, math.Float64bits(p.width))
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes projections and a width encoded by MarshalBinary
+// into the pstable.PStable builder
+func (p *PStable) UnmarshalBinary(data []byte) error {
+	count, dim, seed, buf, err := readHyperplanes(data)
+	if err != nil || buf.Len() != 8 {
+		return errEncoding
+	}
+	var bits uint64
+	binary.Read(buf, binary.LittleEndian, &bits)
+	p.hyperplanes, p.width = newHyperplanes(seed, count, dim),
+		math.Float64frombits(bits)
+	return nil
+}
@@ -0,0 +1,91 @@
+package hash
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestOnlineMarshalBinary(t *testing.T) {
+	online := NewOnline(20, 5)
+	data, err := online.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Online
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(online, decoded) {
+		t.Fatal("decoded simhash.Online differs")
+	}
+	if decoded.UnmarshalBinary(data[:len(data)-1]) == nil {
+		t.Fatal("decoded a truncated simhash.Online")
+	}
+}
+
+func TestMinhashMarshalBinary(t *testing.T) {
+	minhash := NewMinhash(20)
+	data, err := minhash.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Minhash
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(minhash, decoded) {
+		t.Fatal("decoded minhash.Minhash differs")
+	}
+	if decoded.UnmarshalBinary(data[:len(data)-1]) == nil {
+		t.Fatal("decoded a truncated minhash.Minhash")
+	}
+}
+
+func TestPStableMarshalBinary(t *testing.T) {
+	pstable := NewPStable(20, 5, 2.5)
+	data, err := pstable.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded PStable
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pstable, decoded) {
+		t.Fatal("decoded pstable.PStable differs")
+	}
+	if decoded.UnmarshalBinary(data[:len(data)-1]) == nil {
+		t.Fatal("decoded a truncated pstable.PStable")
+	}
+}
+
+func TestUnmarshalBinaryOverflow(t *testing.T) {
+	// counts whose byte lengths overflow to the length of the data
+	var minhash bytes.Buffer
+	putUvarint(&minhash, 1<<60)
+	if (&Minhash{}).UnmarshalBinary(minhash.Bytes()) == nil {
+		t.Fatal("decoded a minhash.Minhash with an overflowing count")
+	}
+
+	// hyperplanes are derived from their seed, so however many there are,
+	// decoding them allocates none
+	var online bytes.Buffer
+	putUvarint(&online, 1<<61)
+	putUvarint(&online, 1<<10)
+	online.Write(make([]byte, 8))
+	var decoded Online
+	if err := decoded.UnmarshalBinary(online.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Bits() != 1<<61 || decoded.hyperplanes.kept != nil {
+		t.Fatal("kept the hyperplanes of a huge simhash.Online")
+	}
+	var pstable bytes.Buffer
+	putUvarint(&pstable, 1<<60)
+	putUvarint(&pstable, 1)
+	pstable.Write(make([]byte, 8))
+	if (&PStable{}).UnmarshalBinary(pstable.Bytes()) == nil {
+		t.Fatal("decoded a pstable.PStable without a width")
+	}
+}
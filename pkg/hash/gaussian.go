@@ -0,0 +1,144 @@
+package hash
+
+import (
+	"github.com/justinfargnoli/lshforest/pkg/sparse"
+	"math"
+	"sync"
+)
+
+// keptComponents is the most components of its hyperplanes a hasher keeps in
+// memory. The components of larger hashers are derived each time they're used
+const keptComponents = 1 << 18
+
+// hyperplanes are count gaussian hyperplanes of dimension dim. Component j of
+// hyperplane i is derived from seed, i and j alone, so a hasher of many
+// dimensions needn't keep them. Only the norms are kept, once they're needed.
+// Components are float32, as are the vectors of embedding models, and dot
+// products are taken in float64
+type hyperplanes struct {
+	seed       int64
+	count, dim uint
+	kept       *[][]float32 // every hyperplane, if there are few enough
+	normsOnce  sync.Once
+	norms      []float64
+}
+
+// newHyperplanes constructs the hyperplanes derived from seed, keeping them if
+// they have no more than keptComponents components
+func newHyperplanes(seed int64, count, dim uint) *hyperplanes {
+	h := &hyperplanes{seed: seed, count: count, dim: dim}
+	// divided rather than multiplied, so a corrupt count can't overflow it
+	if count == 0 || dim <= keptComponents/count {
+		kept := make([][]float32, count)
+		for i := range kept {
+			kept[i] = make([]float32, dim)
+			for j := range kept[i] {
+				kept[i][j] = component(seed, uint(i), uint(j))
+			}
+		}
+		h.kept = &kept
+	}
+	return h
+}
+
+// hyperplane derives hyperplane i, widened to a Hyperplane
+func (h *hyperplanes) hyperplane(i uint) Hyperplane {
+	hyperplane := make(Hyperplane, h.dim)
+	for j := range hyperplane {
+		hyperplane[j] = float64(component(h.seed, i, uint(j)))
+	}
+	return hyperplane
+}
+
+// hyperplaneDot returns the dot product of hyperplane i and the vector. The
+// zero components of the vector are skipped when the hyperplane is derived,
+// which doesn't change the sum
+func hyperplaneDot[F Float](h *hyperplanes, i uint, vector *[]F) float64 {
+	var dotProduct float64
+	if h.kept != nil {
+		hyperplane := (*h.kept)[i]
+		for j, v := range *vector {
+			dotProduct += float64(hyperplane[j]) * float64(v)
+		}
+		return dotProduct
+	}
+	for j, v := range *vector {
+		if v != 0 {
+			dotProduct += float64(component(h.seed, i, uint(j))) * float64(v)
+		}
+	}
+	return dotProduct
+}
+
+// dotSparse returns the dot product of hyperplane i and a sparse vector
+func (h *hyperplanes) dotSparse(i uint, vector *sparse.Vector) float64 {
+	var dotProduct float64
+	for k, index := range vector.Indices() {
+		dotProduct += float64(h.component(i, index)) * vector.Values()[k]
+	}
+	return dotProduct
+}
+
+// component returns component j of hyperplane i
+func (h *hyperplanes) component(i, j uint) float32 {
+	if h.kept != nil {
+		return (*h.kept)[i][j]
+	}
+	return component(h.seed, i, j)
+}
+
+// norm returns the norm of hyperplane i. The norms of every hyperplane are
+// computed the first time one is needed
+func (h *hyperplanes) norm(i uint) float64 {
+	h.normsOnce.Do(func() {
+		h.norms = make([]float64, h.count)
+		for i := range h.norms {
+			var norm float64
+			for j := uint(0); j < h.dim; j++ {
+				c := float64(h.component(uint(i), j))
+				norm += c * c
+			}
+			h.norms[i] = math.Sqrt(norm)
+		}
+	})
+	return h.norms[i]
+}
+
+// component returns component j of hyperplane i of the hyperplanes seeded with
+// seed, a standard normal variate rounded to float32
+func component(seed int64, i, j uint) float32 {
+	return float32(gaussian(seed, i, j))
+}
+
+// gaussian returns a standard normal variate for position (i, j) of the
+// hasher seeded with seed, by the Box-Muller transform of two uniform variates
+func gaussian(seed int64, i, j uint) float64 {
+	x := random(seed, i, j)
+	// u1 is in (0, 1], so its logarithm is finite
+	u1 := float64(x>>11+1) / (1 << 53)
+	u2 := float64(splitmix64(x)>>11) / (1 << 53)
+	return math.Sqrt(-2*math.Log(u1)) * math.Cos(2*math.Pi*u2)
+}
+
+// uniform returns a variate drawn uniformly from [0, 1) for position (i, j)
+// of the hasher seeded with seed
+func uniform(seed int64, i, j uint) float64 {
+	return float64(random(seed, i, j)>>11) / (1 << 53)
+}
+
+// random returns 64 random bits for position (i, j) of the hasher seeded with
+// seed. The position is hashed rather than a generator advanced to it, so
+// any position can be drawn in constant time
+func random(seed int64, i, j uint) uint64 {
+	return splitmix64(splitmix64(splitmix64(uint64(seed))^uint64(i)) ^
+		uint64(j))
+}
+
+// splitmix64 is the finalizer of the SplitMix64 generator, a bijection which
+// mixes every bit of x into every bit of the result
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
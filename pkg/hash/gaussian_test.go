@@ -0,0 +1,80 @@
+package hash
+
+import (
+	"github.com/justinfargnoli/lshforest/pkg/sparse"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestGaussian(t *testing.T) {
+	const n = 100000
+	var sum, squares float64
+	for i := uint(0); i < n; i++ {
+		g := gaussian(1, i/100, i%100)
+		sum += g
+		squares += g * g
+	}
+	mean, variance := sum/n, squares/n-(sum/n)*(sum/n)
+	if math.Abs(mean) > 0.02 || math.Abs(variance-1) > 0.02 {
+		t.Fatalf("expected: (0, 1) | got: (%v, %v)", mean, variance)
+	}
+	if gaussian(1, 2, 3) != gaussian(1, 2, 3) ||
+		gaussian(1, 2, 3) == gaussian(1, 3, 2) ||
+		gaussian(1, 2, 3) == gaussian(2, 2, 3) {
+		t.Fatal("gaussian isn't a function of its seed and position")
+	}
+}
+
+func TestDerivedHyperplanes(t *testing.T) {
+	dense := []float64{0, 1.5, 0, 0, -2, 0, 0.25, 0}
+	vector := sparse.FromDense(&dense)
+	online := NewOnline(50, 8, WithSeed(1))
+	for i, hyperplane := range *NewHyperplanes(50, 8, WithSeed(1)) {
+		for j, c := range hyperplane {
+			if c != float64((*online.hyperplanes.kept)[i][j]) {
+				t.Fatal("simhash.Online's hyperplanes differ from " +
+					"NewHyperplanes'")
+			}
+		}
+	}
+	derived := Online{hyperplanes: &hyperplanes{seed: online.hyperplanes.seed,
+		count: 50, dim: 8}}
+	simhash, margins := online.HashMargins(&dense)
+	derivedSimhash, derivedMargins := derived.HashMargins(&dense)
+	if !simhash.Equal(derivedSimhash) ||
+		!derived.HashSparse(vector).Equal(simhash) {
+		t.Fatalf("expected: (%v) | got: (%v)", simhash.Bits(),
+			derivedSimhash.Bits())
+	}
+	if !reflect.DeepEqual(margins, derivedMargins) {
+		t.Fatalf("expected: (%v) | got: (%v)", margins, derivedMargins)
+	}
+
+	pstable := NewPStable(50, 8, 1, WithSeed(1))
+	projections := NewProjections(50, 8, 1, WithSeed(1))
+	if !pstable.Hash(&dense).Equal(PStableSketch(projections, 1, &dense)) {
+		t.Fatal("pstable.PStable's projections differ from NewProjections'")
+	}
+	derivedPStable := PStable{hyperplanes: &hyperplanes{
+		seed: pstable.hyperplanes.seed, count: 50, dim: 8}, width: 1}
+	if !derivedPStable.Hash(&dense).Equal(pstable.Hash(&dense)) ||
+		!derivedPStable.HashSparse(vector).Equal(pstable.Hash(&dense)) {
+		t.Fatal("derived projections hash differently")
+	}
+}
+
+func TestKeptComponents(t *testing.T) {
+	if NewOnline(20, 1000).hyperplanes.kept == nil {
+		t.Fatal("didn't keep 20000 components")
+	}
+	// a forest of sparse vectors of two million dimensions keeps none
+	online := NewOnline(20, 2000000)
+	if online.hyperplanes.kept != nil {
+		t.Fatal("kept 40000000 components")
+	}
+	vector, _ := sparse.New([]uint{1999999, 7}, []float64{1, -1})
+	if online.HashSparse(vector).Len() != 20 {
+		t.Fatal("couldn't hash a sparse vector")
+	}
+}
@@ -1,9 +1,92 @@
 package hash
 
+import (
+	"github.com/justinfargnoli/lshforest/pkg/sparse"
+	"math/rand"
+)
+
 // Bit represents a bit in an element's hash bit array
 type Bit uint8
 
 // Hasher is a type which can hash a []float64
 type Hasher interface {
-	Hash(*[]float64) *[]Bit
+	Hash(*[]float64) Signature
+}
+
+// Prober is a Hasher which can tell how close a vector is to flipping each bit
+// of its hash
+type Prober interface {
+	Hasher
+	// HashMargins returns the hash of the vector along with the margin of
+	// each bit, the distance from the vector to where the bit flips. The
+	// smaller the margin, the less confident the bit
+	HashMargins(*[]float64) (Signature, []float64)
+}
+
+// SparseHasher is a Hasher which can hash a sparse vector by touching only its
+// non-zero components. A sparse vector hashes to the same signature as its
+// dense form
+type SparseHasher interface {
+	Hasher
+	HashSparse(*sparse.Vector) Signature
+}
+
+// Float32Hasher is a Hasher which can hash a float32 vector directly. The
+// hyperplanes are float32 too and the dot products are taken in float64, so a
+// float32 vector hashes to the same signature as its float64 form
+type Float32Hasher interface {
+	Hasher
+	Hash32(*[]float32) Signature
+}
+
+// Float32Prober is a Prober which can hash a float32 vector directly, as a
+// Float32Hasher does
+type Float32Prober interface {
+	Prober
+	Float32Hasher
+	HashMargins32(*[]float32) (Signature, []float64)
+}
+
+// Float is a type of the components of a dense vector which a hasher can hash
+// and the lshforest package can store
+type Float interface {
+	~float32 | ~float64
+}
+
+// Option configures how a hasher is constructed
+type Option func(*config)
+
+type config struct {
+	rand *rand.Rand
+}
+
+// WithSeed constructs the hasher from a random source seeded with seed, so the
+// same seed always constructs the same hasher
+func WithSeed(seed int64) Option {
+	return WithSource(rand.NewSource(seed))
+}
+
+// WithSource constructs the hasher from the given random source
+func WithSource(source rand.Source) Option {
+	return func(c *config) {
+		c.rand = rand.New(source)
+	}
+}
+
+// seed draws the seed a hasher derives its hyperplanes from
+func (c config) seed() int64 {
+	return c.rand.Int63()
+}
+
+// newConfig applies opts. Without a random source, one is seeded from the
+// global math/rand source
+func newConfig(opts []Option) config {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.rand == nil {
+		c.rand = rand.New(rand.NewSource(rand.Int63()))
+	}
+	return c
 }
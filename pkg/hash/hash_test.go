@@ -2,6 +2,8 @@ package hash
 
 import (
 	"fmt"
+	"github.com/justinfargnoli/lshforest/pkg/sparse"
+	"reflect"
 	"testing"
 )
 
@@ -10,3 +12,45 @@ func TestHasher(t *testing.T) {
 	hash = NewOnline(1, 1)
 	_ = fmt.Sprintf("(%v)", hash)
 }
+
+func TestHashSparse(t *testing.T) {
+	dense := []float64{0, 1.5, 0, 0, -2, 0, 0.25, 0}
+	vector := sparse.FromDense(&dense)
+	for _, hasher := range []SparseHasher{
+		NewOnline(100, 8, WithSeed(1)),
+		NewPStable(100, 8, 1, WithSeed(1)),
+		NewMinhash(100, WithSeed(1)),
+	} {
+		if !hasher.HashSparse(vector).Equal(hasher.Hash(&dense)) {
+			t.Fatalf("(%T) hashes a sparse vector differently", hasher)
+		}
+	}
+}
+
+func TestHash32(t *testing.T) {
+	vector := []float32{0.1, -1.5, 0, 3.25, 0.7, 0, -0.01, 2}
+	widened := make([]float64, len(vector))
+	for i, v := range vector {
+		widened[i] = float64(v)
+	}
+	for _, hasher := range []Float32Hasher{
+		NewOnline(100, 8, WithSeed(1)),
+		NewPStable(100, 8, 1, WithSeed(1)),
+		NewMinhash(100, WithSeed(1)),
+	} {
+		if !hasher.Hash32(&vector).Equal(hasher.Hash(&widened)) {
+			t.Fatalf("(%T) hashes a float32 vector differently", hasher)
+		}
+		prober, ok := hasher.(Float32Prober)
+		if !ok {
+			continue
+		}
+		signature, margins := prober.HashMargins32(&vector)
+		expected, expectedMargins := prober.HashMargins(&widened)
+		if !signature.Equal(expected) ||
+			!reflect.DeepEqual(margins, expectedMargins) {
+			t.Fatalf("(%T) has different margins for a float32 vector",
+				hasher)
+		}
+	}
+}
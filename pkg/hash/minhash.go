@@ -0,0 +1,103 @@
+package hash
+
+import (
+	"github.com/justinfargnoli/lshforest/pkg/sparse"
+	"math/bits"
+)
+
+// mersennePrime is the modulus of each Permutation, 2^61 - 1
+const mersennePrime = uint64(1)<<61 - 1
+
+// Minhash builds minhash data sketches online. A vector is treated as the set
+// of the indices of its non-zero components
+type Minhash struct {
+	permutations *[]Permutation
+}
+
+// NewMinhash constructs a minhash.Minhash builder given the number of
+// permutations, and therefore bits, in each sketch
+func NewMinhash(permutationCount uint, opts ...Option) Minhash {
+	return Minhash{permutations: NewPermutations(permutationCount, opts...)}
+}
+
+// Bits returns the number of bits in each sketch, one for each permutation
+func (m Minhash) Bits() uint {
+	return uint(len(*m.permutations))
+}
+
+// Hash constructs a minhash data sketch of the given vector
+func (m Minhash) Hash(vector *[]float64) Signature {
+	return MinhashSketch(m.permutations, vector)
+}
+
+// Hash32 constructs a minhash data sketch of the given float32 vector
+func (m Minhash) Hash32(vector *[]float32) Signature {
+	return minhashSketch(m.permutations, vector)
+}
+
+// HashSparse constructs a minhash data sketch of the set of the indices of the
+// given sparse vector
+func (m Minhash) HashSparse(vector *sparse.Vector) Signature {
+	sketch := NewSignature(uint(len(*m.permutations)))
+	for i, permutation := range *m.permutations {
+		min := ^uint64(0)
+		for _, index := range vector.Indices() {
+			if h := permutation.apply(uint64(index)); h < min {
+				min = h
+			}
+		}
+		sketch.Set(uint(i), Bit(min&1))
+	}
+	return sketch
+}
+
+// MinhashSketch constructs a 1-bit minhash data sketch of the given vector.
+// Bit i is the lowest bit of the minimum of permutations[i] over the set, so
+// two sets agree on a bit with probability J + (1 - J) / 2 where J is their
+// jaccard similarity
+func MinhashSketch(permutations *[]Permutation, vector *[]float64) Signature {
+	return minhashSketch(permutations, vector)
+}
+
+func minhashSketch[F Float](permutations *[]Permutation,
+	vector *[]F) Signature {
+	sketch := NewSignature(uint(len(*permutations)))
+	for i, permutation := range *permutations {
+		min := ^uint64(0)
+		for j, v := range *vector {
+			if v == 0 {
+				continue
+			}
+			if h := permutation.apply(uint64(j)); h < min {
+				min = h
+			}
+		}
+		sketch.Set(uint(i), Bit(min&1))
+	}
+	return sketch
+}
+
+// Permutation is the universal hash function (A*x + B) mod (2^61 - 1), which
+// stands in for a random permutation of the indices of a vector
+type Permutation struct {
+	A, B uint64
+}
+
+// NewPermutations constructs the given number of random permutations
+func NewPermutations(count uint, opts ...Option) *[]Permutation {
+	c := newConfig(opts)
+	permutations := make([]Permutation, count)
+	for i := range permutations {
+		permutations[i] = Permutation{
+			A: 1 + uint64(c.rand.Int63n(int64(mersennePrime-1))),
+			B: uint64(c.rand.Int63n(int64(mersennePrime))),
+		}
+	}
+	return &permutations
+}
+
+func (p Permutation) apply(x uint64) uint64 {
+	hi, lo := bits.Mul64(p.A, x)
+	lo, carry := bits.Add64(lo, p.B, 0)
+	return bits.Rem64(hi+carry, lo, mersennePrime)
+}
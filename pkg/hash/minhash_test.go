@@ -0,0 +1,33 @@
+package hash
+
+import "testing"
+
+func TestMinhash(t *testing.T) {
+	vectors := [][]float64{
+		{1, 0, 1, 1, 0, 0, 1},
+		{0, 1, 0, 1, 0, 1, 0},
+		{1, 0, 1, 1, 0, 0, 1},
+	}
+	minhash := NewMinhash(300)
+	var sketches []Signature
+	for i := range vectors {
+		sketches = append(sketches, minhash.Hash(&vectors[i]))
+	}
+	if sketches[0].Len() != 300 {
+		t.Fatalf("expected: (300) bits | got: (%v)", sketches[0].Len())
+	}
+	if !sketches[0].Equal(sketches[2]) {
+		t.Fatal("equal sets have different sketches")
+	}
+}
+
+func TestPermutation(t *testing.T) {
+	p := Permutation{A: mersennePrime - 1, B: mersennePrime - 1}
+	if h := p.apply(1); h != mersennePrime-2 {
+		t.Fatalf("expected: (%v) | got: (%v)", mersennePrime-2, h)
+	}
+	// (p - 1)(p - 1) + (p - 1) = p(p - 1) = 0 mod p
+	if h := p.apply(mersennePrime - 1); h != 0 {
+		t.Fatalf("expected: (0) | got: (%v)", h)
+	}
+}
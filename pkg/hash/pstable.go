@@ -0,0 +1,176 @@
+package hash
+
+import (
+	"github.com/justinfargnoli/lshforest/pkg/sparse"
+	"math"
+)
+
+// PStable builds p-stable data sketches online for euclidean distance. Each
+// projection splits its line into buckets of equal width and contributes the
+// parity of the bucket the vector falls into as a bit. Its projections are
+// derived from a seed, as Online's hyperplanes are
+type PStable struct {
+	hyperplanes *hyperplanes
+	width       float64
+}
+
+// NewPStable constructs a pstable.PStable builder given the number of
+// projections to construct, the dimension of the input vectors and the width
+// of each bucket. The wider the buckets are, the farther apart vectors can be
+// and still agree on a bit. Its projections are those NewProjections
+// constructs with the same options
+func NewPStable(projectionCount, dim uint, width float64,
+	opts ...Option) PStable {
+	return PStable{hyperplanes: newHyperplanes(newConfig(opts).seed(),
+		projectionCount, dim), width: width}
+}
+
+// Bits returns the number of bits in each sketch, one for each projection
+func (p PStable) Bits() uint {
+	return p.hyperplanes.count
+}
+
+// Dim returns the dimension of the vectors the builder hashes
+func (p PStable) Dim() uint {
+	return p.hyperplanes.dim
+}
+
+// Hash constructs a p-stable data sketch of the given vector
+func (p PStable) Hash(vector *[]float64) Signature {
+	return pstableOf(p.Bits(), p.width, pstablePosition(p, vector))
+}
+
+// Hash32 constructs a p-stable data sketch of the given float32 vector
+func (p PStable) Hash32(vector *[]float32) Signature {
+	return pstableOf(p.Bits(), p.width, pstablePosition(p, vector))
+}
+
+// HashSparse constructs a p-stable data sketch of the given sparse vector
+func (p PStable) HashSparse(vector *sparse.Vector) Signature {
+	return pstableOf(p.Bits(), p.width, func(i uint) float64 {
+		return p.hyperplanes.dotSparse(i, vector) + p.offset(i)
+	})
+}
+
+// HashMargins constructs a p-stable data sketch of the given vector along with
+// the distance from the vector to the nearest edge of each bucket it falls into
+func (p PStable) HashMargins(vector *[]float64) (Signature, []float64) {
+	return pstableMarginsOf(p.Bits(), p.width, pstablePosition(p, vector),
+		p.hyperplanes.norm)
+}
+
+// HashMargins32 constructs a p-stable data sketch of the given float32 vector
+// along with the distance from the vector to the nearest edge of each bucket
+// it falls into
+func (p PStable) HashMargins32(vector *[]float32) (Signature, []float64) {
+	return pstableMarginsOf(p.Bits(), p.width, pstablePosition(p, vector),
+		p.hyperplanes.norm)
+}
+
+// offset returns the offset of projection i
+func (p PStable) offset(i uint) float64 {
+	return projectionOffset(p.hyperplanes.seed, i, p.width)
+}
+
+// pstablePosition returns the position of the vector along each of the
+// builder's projections
+func pstablePosition[F Float](p PStable, vector *[]F) func(uint) float64 {
+	return func(i uint) float64 {
+		return hyperplaneDot(p.hyperplanes, i, vector) + p.offset(i)
+	}
+}
+
+// PStableSketch constructs a p-stable data sketch of the given vector. Bit i
+// is the parity of floor((projections[i].Hyperplane . vector +
+// projections[i].Offset) / width)
+func PStableSketch(projections *[]Projection, width float64,
+	vector *[]float64) Signature {
+	return pstableSketch(projections, width, vector)
+}
+
+func pstableSketch[F Float](projections *[]Projection, width float64,
+	vector *[]F) Signature {
+	return pstableOf(uint(len(*projections)), width, func(i uint) float64 {
+		return project((*projections)[i], vector)
+	})
+}
+
+// PStableMargins constructs a p-stable data sketch of the given vector along
+// with the distance from the vector to the nearest edge of each bucket it
+// falls into, measured along the projection's hyperplane
+func PStableMargins(projections *[]Projection, width float64,
+	vector *[]float64) (Signature, []float64) {
+	return pstableMarginsOf(uint(len(*projections)), width,
+		func(i uint) float64 {
+			return project((*projections)[i], vector)
+		}, func(i uint) float64 {
+			return (*projections)[i].Hyperplane.norm()
+		})
+}
+
+// pstableOf constructs the p-stable data sketch whose bit i is the parity of
+// the bucket of width width which position(i) falls into
+func pstableOf(count uint, width float64,
+	position func(uint) float64) Signature {
+	sketch := NewSignature(count)
+	for i := uint(0); i < count; i++ {
+		bucket := math.Floor(position(i) / width)
+		sketch.Set(i, Bit(int64(bucket)&1))
+	}
+	return sketch
+}
+
+// pstableMarginsOf constructs the data sketch pstableOf does along with the
+// margin of each bit, the distance from position(i) to the nearest edge of its
+// bucket divided by norm(i)
+func pstableMarginsOf(count uint, width float64,
+	position, norm func(uint) float64) (Signature, []float64) {
+	sketch := NewSignature(count)
+	margins := make([]float64, count)
+	for i := uint(0); i < count; i++ {
+		scaled := position(i) / width
+		bucket := math.Floor(scaled)
+		sketch.Set(i, Bit(int64(bucket)&1))
+		edge := math.Min(scaled-bucket, bucket+1-scaled) * width
+		margins[i] = edge / norm(i)
+	}
+	return sketch, margins
+}
+
+// Projection is a gaussian projection of a vector onto a line shifted by
+// Offset
+type Projection struct {
+	Hyperplane Hyperplane
+	Offset     float64
+}
+
+// NewProjections constructs the given number of projections of dim
+// dimensional vectors with offsets drawn uniformly from [0, width)
+func NewProjections(count, dim uint, width float64,
+	opts ...Option) *[]Projection {
+	h := hyperplanes{seed: newConfig(opts).seed(), count: count, dim: dim}
+	projections := make([]Projection, count)
+	for i := range projections {
+		projections[i] = Projection{Hyperplane: h.hyperplane(uint(i)),
+			Offset: projectionOffset(h.seed, uint(i), width)}
+	}
+	return &projections
+}
+
+// projectionOffset returns the offset of projection i of the projections
+// seeded with seed. It's drawn at a position past any component
+func projectionOffset(seed int64, i uint, width float64) float64 {
+	return uniform(seed, i, math.MaxUint) * width
+}
+
+// project returns the position of vector along the projection's line, taken
+// in float64
+func project[F Float](p Projection, vector *[]F) float64 {
+	return dot(p.Hyperplane, vector) + p.Offset
+}
+
+// projectSparse is project for a sparse vector. The sum is taken in the same
+// order, so a sparse vector projects to the same point as its dense form
+func (p Projection) projectSparse(vector *sparse.Vector) float64 {
+	return p.Hyperplane.dotSparse(vector) + p.Offset
+}
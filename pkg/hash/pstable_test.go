@@ -0,0 +1,49 @@
+package hash
+
+import "testing"
+
+func TestPStable(t *testing.T) {
+	vectors := [][]float64{
+		{0.0, 2.0, 3.3, -4.2},
+		{0.0, 2.0, 3.3, -4.1},
+		{100, -200, 300, 400},
+	}
+	pstable := NewPStable(300, 4, 4, WithSeed(1))
+	var sketches []Signature
+	for i := range vectors {
+		sketches = append(sketches, pstable.Hash(&vectors[i]))
+	}
+	near, far := sketches[0].Hamming(sketches[1]), sketches[0].Hamming(sketches[2])
+	if near >= far {
+		t.Fatalf("near vectors differ in (%v) bits | far vectors in (%v)",
+			near, far)
+	}
+}
+
+func TestPStableSketch(t *testing.T) {
+	projections := []Projection{
+		{Hyperplane: Hyperplane{1, 0}, Offset: 0.5},
+		{Hyperplane: Hyperplane{0, 1}, Offset: 0},
+	}
+	// buckets: floor(3.5 / 2) = 1 and floor(-3 / 2) = -2
+	sketch := PStableSketch(&projections, 2, &[]float64{3, -3})
+	if !sketch.Equal(FromBits(1, 0)) {
+		t.Fatalf("expected: ([1 0]) | got: (%v)", sketch.Bits())
+	}
+}
+
+func TestPStableMargins(t *testing.T) {
+	projections := []Projection{
+		{Hyperplane: Hyperplane{1, 0}, Offset: 0.5},
+		{Hyperplane: Hyperplane{0, 2}, Offset: 0},
+	}
+	sketch, margins := PStableMargins(&projections, 2, &[]float64{3, -1.25})
+	if !sketch.Equal(PStableSketch(&projections, 2, &[]float64{3, -1.25})) {
+		t.Fatalf("got: (%v)", sketch.Bits())
+	}
+	// 3.5 is 0.5 from the edge at 4, and -2.5 is 0.5 from -2, which is 0.25
+	// along the hyperplane of length 2
+	if margins[0] != 0.5 || margins[1] != 0.25 {
+		t.Fatalf("expected: ([0.5 0.25]) | got: (%v)", margins)
+	}
+}
@@ -0,0 +1,109 @@
+package hash
+
+import "math/bits"
+
+// Signature is a hash bit array packed into 64-bit words. Bit i is bit i % 64
+// of word i / 64
+type Signature struct {
+	words []uint64
+	n     uint
+}
+
+// NewSignature constructs a signature of n zero bits
+func NewSignature(n uint) Signature {
+	return Signature{words: make([]uint64, (n+63)/64), n: n}
+}
+
+// FromBits constructs the signature of the given bit array
+func FromBits(bits ...Bit) Signature {
+	s := NewSignature(uint(len(bits)))
+	for i, bit := range bits {
+		s.Set(uint(i), bit)
+	}
+	return s
+}
+
+// FromWords constructs the signature of the first n bits packed into words.
+// It takes ownership of words
+func FromWords(words []uint64, n uint) Signature {
+	if uint(len(words)) != (n+63)/64 {
+		panic("lshforest/hash FromWords(): len(words) != (n + 63) / 64")
+	}
+	if n%64 != 0 {
+		words[len(words)-1] &= uint64(1)<<(n%64) - 1
+	}
+	return Signature{words: words, n: n}
+}
+
+// Len returns the number of bits in the signature
+func (s Signature) Len() uint {
+	return s.n
+}
+
+// Words returns the words the bits of the signature are packed into
+func (s Signature) Words() []uint64 {
+	return s.words
+}
+
+// Bit returns bit i of the signature
+func (s Signature) Bit(i uint) Bit {
+	return Bit(s.words[i/64] >> (i % 64) & 1)
+}
+
+// Set sets bit i of the signature to bit
+func (s Signature) Set(i uint, bit Bit) {
+	if bit == 0 {
+		s.words[i/64] &^= 1 << (i % 64)
+	} else {
+		s.words[i/64] |= 1 << (i % 64)
+	}
+}
+
+// Flip returns a copy of the signature with bit i flipped
+func (s Signature) Flip(i uint) Signature {
+	words := append([]uint64{}, s.words...)
+	words[i/64] ^= 1 << (i % 64)
+	return Signature{words: words, n: s.n}
+}
+
+// Bits returns the signature as an unpacked bit array
+func (s Signature) Bits() []Bit {
+	bits := make([]Bit, s.n)
+	for i := range bits {
+		bits[i] = s.Bit(uint(i))
+	}
+	return bits
+}
+
+// Equal returns whether the signatures have the same bits
+func (s Signature) Equal(o Signature) bool {
+	return s.n == o.n && s.Hamming(o) == 0
+}
+
+// Hamming returns the number of bits in which the signatures differ. Both
+// signatures must be the same length
+func (s Signature) Hamming(o Signature) uint {
+	var distance int
+	for i, word := range s.words {
+		distance += bits.OnesCount64(word ^ o.words[i])
+	}
+	return uint(distance)
+}
+
+// CommonPrefix returns the length of the longest common prefix of the
+// signatures
+func (s Signature) CommonPrefix(o Signature) uint {
+	n := s.n
+	if o.n < n {
+		n = o.n
+	}
+	for i := uint(0); i*64 < n; i++ {
+		if diff := s.words[i] ^ o.words[i]; diff != 0 {
+			if prefix := i*64 + uint(bits.TrailingZeros64(diff)); prefix < n {
+				return prefix
+			}
+			return n
+		}
+	}
+	return n
+}
@@ -0,0 +1,48 @@
+package hash
+
+import "testing"
+
+func TestSignature(t *testing.T) {
+	var bits []Bit
+	for i := 0; i < 130; i++ {
+		bits = append(bits, Bit(i%3%2))
+	}
+	s := FromBits(bits...)
+	if s.Len() != 130 || len(s.Words()) != 3 {
+		t.Fatalf("got: (%v) bits in (%v) words", s.Len(), len(s.Words()))
+	}
+	for i, bit := range s.Bits() {
+		if bit != bits[i] {
+			t.Fatalf("bit (%v) expected: (%v) | got: (%v)", i, bits[i], bit)
+		}
+	}
+	if !s.Equal(FromWords(append([]uint64{}, s.Words()...), 130)) {
+		t.Fatal("FromWords() differs from FromBits()")
+	}
+
+	o := FromBits(bits...)
+	o.Set(3, 1-o.Bit(3))
+	o.Set(100, 1-o.Bit(100))
+	o.Set(129, 1-o.Bit(129))
+	if d := s.Hamming(o); d != 3 {
+		t.Fatalf("expected: (3) | got: (%v)", d)
+	}
+	if p := s.CommonPrefix(o); p != 3 {
+		t.Fatalf("expected: (3) | got: (%v)", p)
+	}
+	if p := s.CommonPrefix(s); p != 130 {
+		t.Fatalf("expected: (130) | got: (%v)", p)
+	}
+	if p := FromBits(1, 0, 1).CommonPrefix(FromBits(1, 0)); p != 2 {
+		t.Fatalf("expected: (2) | got: (%v)", p)
+	}
+	if s.Equal(o) || !FromBits().Equal(NewSignature(0)) {
+		t.Fatal("Equal() is wrong")
+	}
+
+	flipped := s.Flip(129)
+	if flipped.Bit(129) == s.Bit(129) || s.Hamming(flipped) != 1 ||
+		!s.Equal(FromBits(bits...)) {
+		t.Fatal("Flip() is wrong")
+	}
+}
@@ -1,63 +1,171 @@
 package hash
 
-import "math/rand"
+import (
+	"github.com/justinfargnoli/lshforest/pkg/sparse"
+	"math"
+)
 
-// Online builds simhash data sketches online
+// Online builds simhash data sketches online. Its hyperplanes are derived from
+// a seed, so they only take up memory if they're small
 type Online struct {
-	hyperplanes *[]Hyperplane
+	hyperplanes *hyperplanes
 }
 
 // NewOnline constructs a simhash.Online builder given the number of hyperplanes
-// to construct and the dimension of the input vectors
-func NewOnline(hyperplaneCount, dim uint) Online {
-	return Online{hyperplanes: NewHyperplanes(hyperplaneCount, dim)}
+// to construct and the dimension of the input vectors. Its hyperplanes are
+// those NewHyperplanes constructs with the same options
+func NewOnline(hyperplaneCount, dim uint, opts ...Option) Online {
+	return Online{hyperplanes: newHyperplanes(newConfig(opts).seed(),
+		hyperplaneCount, dim)}
+}
+
+// Bits returns the number of bits in each sketch, one for each hyperplane
+func (o Online) Bits() uint {
+	return o.hyperplanes.count
+}
+
+// Dim returns the dimension of the vectors the builder hashes
+func (o Online) Dim() uint {
+	return o.hyperplanes.dim
 }
 
 // Hash constructs a simhash data sketch of the given vector
-func (o Online) Hash(vector *[]float64) *[]Bit {
-	return NewSimhash(o.hyperplanes, vector)
+func (o Online) Hash(vector *[]float64) Signature {
+	return simhashOf(o.Bits(), onlineDot(o, vector))
+}
+
+// Hash32 constructs a simhash data sketch of the given float32 vector
+func (o Online) Hash32(vector *[]float32) Signature {
+	return simhashOf(o.Bits(), onlineDot(o, vector))
+}
+
+// HashSparse constructs a simhash data sketch of the given sparse vector
+func (o Online) HashSparse(vector *sparse.Vector) Signature {
+	return simhashOf(o.Bits(), func(i uint) float64 {
+		return o.hyperplanes.dotSparse(i, vector)
+	})
+}
+
+// HashMargins constructs a simhash data sketch of the given vector along with
+// the distance from the vector to each hyperplane
+func (o Online) HashMargins(vector *[]float64) (Signature, []float64) {
+	return simhashMarginsOf(o.Bits(), onlineDot(o, vector),
+		o.hyperplanes.norm)
+}
+
+// HashMargins32 constructs a simhash data sketch of the given float32 vector
+// along with the distance from the vector to each hyperplane
+func (o Online) HashMargins32(vector *[]float32) (Signature, []float64) {
+	return simhashMarginsOf(o.Bits(), onlineDot(o, vector),
+		o.hyperplanes.norm)
+}
+
+// onlineDot returns the dot product of each of the builder's hyperplanes and
+// the vector
+func onlineDot[F Float](o Online, vector *[]F) func(uint) float64 {
+	return func(i uint) float64 {
+		return hyperplaneDot(o.hyperplanes, i, vector)
+	}
 }
 
 // Offline sketches each vector in an offline fashion
-func Offline(vectors *[][]float64, hyperplaneCount uint) *[][]Bit {
-	simhashs := make([][]Bit, len(*vectors))
-	hyperplanes := NewHyperplanes(hyperplaneCount, uint(len((*vectors)[0])))
+func Offline(vectors *[][]float64, hyperplaneCount uint,
+	opts ...Option) *[]Signature {
+	simhashs := make([]Signature, len(*vectors))
+	hyperplanes := NewHyperplanes(hyperplaneCount, uint(len((*vectors)[0])),
+		opts...)
 	for i, vector := range *vectors {
-		simhashs[i] = *NewSimhash(hyperplanes, &vector)
+		simhashs[i] = NewSimhash(hyperplanes, &vector)
 	}
 	return &simhashs
 }
 
 // NewSimhash constructs a simhash data sketch of the given vector
-func NewSimhash(hyperplanes *[]Hyperplane, vector *[]float64) *[]Bit {
-	simhash := make([]Bit, len(*hyperplanes))
-	for i, hyperplane := range *hyperplanes {
-		var dotProduct float64 // the dot product of hyperplanes[i] and vector
-		for j, v := range *vector {
-			dotProduct += hyperplane[j] * v
+func NewSimhash(hyperplanes *[]Hyperplane, vector *[]float64) Signature {
+	return simhash(hyperplanes, vector)
+}
+
+func simhash[F Float](hyperplanes *[]Hyperplane, vector *[]F) Signature {
+	return simhashOf(uint(len(*hyperplanes)), func(i uint) float64 {
+		return dot((*hyperplanes)[i], vector)
+	})
+}
+
+// SimhashMargins constructs a simhash data sketch of the given vector along
+// with the distance from the vector to each hyperplane, |h . v| / |h|
+func SimhashMargins(hyperplanes *[]Hyperplane,
+	vector *[]float64) (Signature, []float64) {
+	return simhashMarginsOf(uint(len(*hyperplanes)), func(i uint) float64 {
+		return dot((*hyperplanes)[i], vector)
+	}, func(i uint) float64 {
+		return (*hyperplanes)[i].norm()
+	})
+}
+
+// simhashOf constructs the simhash data sketch whose bit i is set if the dot
+// product of hyperplane i and the vector, dot(i), isn't negative
+func simhashOf(count uint, dot func(uint) float64) Signature {
+	simhash := NewSignature(count)
+	for i := uint(0); i < count; i++ {
+		if dot(i) >= 0 {
+			simhash.Set(i, 1)
 		}
+	}
+	return simhash
+}
+
+// simhashMarginsOf constructs the data sketch simhashOf does along with the
+// margin of each bit, |dot(i)| / norm(i)
+func simhashMarginsOf(count uint, dot, norm func(uint) float64) (Signature,
+	[]float64) {
+	simhash := NewSignature(count)
+	margins := make([]float64, count)
+	for i := uint(0); i < count; i++ {
+		dotProduct := dot(i)
 		if dotProduct >= 0 {
-			simhash[i] = Bit(1)
-		} else {
-			simhash[i] = Bit(0)
+			simhash.Set(i, 1)
 		}
+		margins[i] = math.Abs(dotProduct) / norm(i)
 	}
-	return &simhash
+	return simhash, margins
 }
 
 // Hyperplane is a dim dimensional hyperplane
 type Hyperplane []float64
 
+// dot returns the dot product of the hyperplane and vector, taken in float64
+func dot[F Float](h Hyperplane, vector *[]F) float64 {
+	var dotProduct float64
+	for i, v := range *vector {
+		dotProduct += h[i] * float64(v)
+	}
+	return dotProduct
+}
+
+// dotSparse returns the dot product of the hyperplane and a sparse vector
+func (h Hyperplane) dotSparse(vector *sparse.Vector) float64 {
+	var dotProduct float64
+	for i, index := range vector.Indices() {
+		dotProduct += h[index] * vector.Values()[i]
+	}
+	return dotProduct
+}
+
+func (h Hyperplane) norm() float64 {
+	var norm float64
+	for _, x := range h {
+		norm += x * x
+	}
+	return math.Sqrt(norm)
+}
+
 // NewHyperplanes constructs a hyperplane given number of hyperplanes to
 // construct and the dimension of each hyperplane
-func NewHyperplanes(count, dim uint) *[]Hyperplane {
+func NewHyperplanes(count, dim uint, opts ...Option) *[]Hyperplane {
+	h := hyperplanes{seed: newConfig(opts).seed(), count: count, dim: dim}
 	hyperplanes := make([]Hyperplane, count)
-	for i := uint(0); i < count; i++ {
-		hyperplane := make(Hyperplane, dim)
-		for j := uint(0); j < dim; j++ {
-			hyperplane[j] = rand.NormFloat64()
-		}
-		hyperplanes[i] = hyperplane
+	for i := range hyperplanes {
+		hyperplanes[i] = h.hyperplane(uint(i))
 	}
 	return &hyperplanes
 }
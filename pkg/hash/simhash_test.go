@@ -22,3 +22,32 @@ func TestOffline(t *testing.T) {
 	}
 	Offline(&vectors, 300)
 }
+
+func TestNewHyperplanesWithSeed(t *testing.T) {
+	h1 := NewHyperplanes(10, 4, WithSeed(1))
+	h2 := NewHyperplanes(10, 4, WithSeed(1))
+	h3 := NewHyperplanes(10, 4, WithSeed(2))
+	for i := range *h1 {
+		for j := range (*h1)[i] {
+			if (*h1)[i][j] != (*h2)[i][j] {
+				t.Fatal("hyperplanes with the same seed differ")
+			}
+		}
+	}
+	if (*h1)[0][0] == (*h3)[0][0] {
+		t.Fatal("hyperplanes with different seeds are equal")
+	}
+}
+
+func TestSimhashMargins(t *testing.T) {
+	hyperplanes := []Hyperplane{{3, 4}, {0, -1}}
+	simhash, margins := SimhashMargins(&hyperplanes, &[]float64{1, 2})
+	if !simhash.Equal(NewSimhash(&hyperplanes, &[]float64{1, 2})) {
+		t.Fatalf("expected: (%v) | got: (%v)",
+			NewSimhash(&hyperplanes, &[]float64{1, 2}).Bits(), simhash.Bits())
+	}
+	// |3 + 8| / 5 and |-2| / 1
+	if margins[0] != 2.2 || margins[1] != 2 {
+		t.Fatalf("expected: ([2.2 2]) | got: (%v)", margins)
+	}
+}
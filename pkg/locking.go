@@ -0,0 +1,60 @@
+package lshforest
+
+// The locks of an LSHForest are only taken if it's constructed with
+// WithConcurrency. Queries hold the read lock of every tree, taken in order,
+// for as long as they walk the trees. Writers hold the lock of one tree at a
+// time, so they can't deadlock with queries. Writers also share the writers
+// lock, which WriteTo holds exclusively so it never sees a half inserted or
+// deleted element
+
+func (f *LSHForest[V]) lock() {
+	if f.concurrent {
+		f.mu.Lock()
+	}
+}
+
+func (f *LSHForest[V]) unlock() {
+	if f.concurrent {
+		f.mu.Unlock()
+	}
+}
+
+func (f *LSHForest[V]) beginWrite() {
+	if f.concurrent {
+		f.writers.RLock()
+	}
+}
+
+func (f *LSHForest[V]) endWrite() {
+	if f.concurrent {
+		f.writers.RUnlock()
+	}
+}
+
+func (f *LSHForest[V]) lockTree(i int) {
+	if f.concurrent {
+		f.treeLocks[i].Lock()
+	}
+}
+
+func (f *LSHForest[V]) unlockTree(i int) {
+	if f.concurrent {
+		f.treeLocks[i].Unlock()
+	}
+}
+
+func (f *LSHForest[V]) rlockTrees() {
+	if f.concurrent {
+		for i := range f.treeLocks {
+			f.treeLocks[i].RLock()
+		}
+	}
+}
+
+func (f *LSHForest[V]) runlockTrees() {
+	if f.concurrent {
+		for i := range f.treeLocks {
+			f.treeLocks[i].RUnlock()
+		}
+	}
+}
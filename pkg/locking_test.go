@@ -0,0 +1,147 @@
+package lshforest
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/justinfargnoli/lshforest/pkg/hash"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestConcurrency(t *testing.T) {
+	const dim, writers, readers, operations = 8, 4, 4, 200
+	lshforest := New(6, 16, dim, Cosine, WithSeed(1), WithConcurrency())
+	for i := 0; i < 50; i++ {
+		vector := randomVector(rand.New(rand.NewSource(int64(i))), dim)
+		if err := lshforest.Insert(&vector, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers+readers+1)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(100 + w)))
+			for i := 0; i < operations; i++ {
+				vector := randomVector(r, dim)
+				var err error
+				switch i % 3 {
+				case 0:
+					err = lshforest.Insert(&vector, i)
+				case 1:
+					// the first 50 ids are only deleted by writer 0
+					if w == 0 && i < 150 {
+						err = lshforest.Delete(uint(i / 3))
+					}
+				case 2:
+					if w == 1 && i < 150 {
+						err = lshforest.Update(uint(i/3), &vector, -i)
+					}
+				}
+				if err != nil && err != ErrNotFound {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	for q := 0; q < readers; q++ {
+		wg.Add(1)
+		go func(q int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(200 + q)))
+			for i := 0; i < operations; i++ {
+				vector := randomVector(r, dim)
+				if _, err := lshforest.Query(&vector, 5); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := lshforest.QueryRadius(&vector, 0.9); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(q)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5; i++ {
+			var buf bytes.Buffer
+			if _, err := lshforest.WriteTo(&buf); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := new(LSHForest[interface{}]).ReadFrom(&buf); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentUpdate(t *testing.T) {
+	const dim, ids, operations = 8, 2, 2000
+	lshforest := New(4, 16, dim, Cosine, WithSeed(1), WithConcurrency())
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < ids; i++ {
+		vector := randomVector(r, dim)
+		if err := lshforest.Insert(&vector, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// every id exists throughout, so InsertWithID never inserts one while
+	// it's being updated
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		r := rand.New(rand.NewSource(2))
+		for i := 0; i < operations; i++ {
+			vector := randomVector(r, dim)
+			if err := lshforest.Update(uint(i%ids), &vector, i); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		r := rand.New(rand.NewSource(3))
+		for i := 0; i < operations; i++ {
+			vector := randomVector(r, dim)
+			err := lshforest.InsertWithID(uint(i%ids), &vector, -i)
+			if err != ErrIDExists {
+				errs <- fmt.Errorf("expected: (%v) | got: (%v)", ErrIDExists,
+					err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	for i, tree := range lshforest.trees {
+		cursor, _ := tree.Descend(hash.NewSignature(16))
+		for parent, ok := cursor.Ascend(); ok; parent, ok = cursor.Ascend() {
+			cursor = parent
+		}
+		if n := len(cursor.Subtree(nil)); n != ids {
+			t.Fatalf("tree (%v) expected: (%v) elements | got: (%v)", i, ids, n)
+		}
+	}
+}
@@ -1,25 +1,63 @@
 package lshforest
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/gaspiman/cosine_similarity"
 	"github.com/justinfargnoli/lshforest/pkg/hash"
 	"github.com/justinfargnoli/lshforest/pkg/lshtree"
+	"github.com/justinfargnoli/lshforest/pkg/sparse"
 	"math"
 	"sort"
+	"sync"
 )
 
-// LSHForest is an index of high-dimensional data based on cosine similarity
-type LSHForest struct {
-	trees   []lshtree.LSHTree
-	hashers []hash.Hasher
-	vecDim  uint
+// LSHForest is an index of high-dimensional data based on cosine similarity,
+// jaccard similarity or euclidean distance, whose elements have values of type
+// V
+type LSHForest[V any] struct {
+	trees      []lshtree.LSHTree[V]
+	hashers    []hash.Hasher
+	similarity func(point, point) float64
+	seeds      []int64
+	metric     uint
+	backend    uint
+	maxK       uint
+	vecDim     uint
+	entries    map[uint]entry[V]
+	pending    map[uint]bool // ids which InsertWithID or Update is inserting
+	nextID     uint
+	codec      ValueCodec
+	multiplier uint
+	probes     uint
+	concurrent bool
+	zeroCopy   bool
+	arenas     arenas
+	workers    int
+	mu         sync.Mutex     // guards entries, pending, nextID and arenas
+	writers    sync.RWMutex   // shared by writers, exclusive to WriteTo
+	treeLocks  []sync.RWMutex // treeLocks[i] guards trees[i]
+}
+
+// entry is the vector and value of an element of the LSHForest, which its
+// elements in every tree share, along with its hash in each tree, by which
+// it's deleted without being rehashed
+type entry[V any] struct {
+	*lshtree.Data[V]
+	hashes []hash.Signature
 }
 
 // NewDefault constructs an LSHForest struct for cosine similarity with
 // sensible defaults
-func NewDefault(dim, metric uint) *LSHForest {
-	return New(5, 20, dim, metric)
+func NewDefault(dim, metric uint, opts ...Option) *LSHForest[interface{}] {
+	return NewDefaultTyped[interface{}](dim, metric, opts...)
+}
+
+// NewDefaultTyped constructs an LSHForest struct whose values have type V
+// with sensible defaults
+func NewDefaultTyped[V any](dim, metric uint, opts ...Option) *LSHForest[V] {
+	return NewTyped[V](5, 20, dim, metric, opts...)
 }
 
 const (
@@ -27,6 +65,18 @@ const (
 	Cosine = uint(0)
 	// Jaccard indicates to use jaccard similarity and minhash
 	Jaccard = uint(1)
+	// Euclidean indicates to use euclidean (L2) distance and p-stable hashing.
+	// Elements are ranked by the similarity 1 / (1 + distance)
+	Euclidean = uint(2)
+)
+
+const (
+	// TrieBackend indicates to store each tree as an lshtree.Trie
+	TrieBackend = uint(0)
+	// SortedBackend indicates to store each tree as an lshtree.Sorted, an
+	// array sorted by hash. It suits indexes which are built once with
+	// InsertAll
+	SortedBackend = uint(1)
 )
 
 var (
@@ -35,164 +85,891 @@ var (
 	// ErrEqDim is throw when the given dimension and dimension of vector aren't
 	// equal
 	ErrEqDim = errors.New("vector's dimension must be equal to dim passed to New")
+	// ErrNotFound is thrown when no element has the given id
+	ErrNotFound = errors.New("no element has the given id")
+	// ErrIDExists is thrown when an element is inserted with the id of an
+	// element which is already in the LSHForest
+	ErrIDExists = errors.New("an element already has the given id")
+	// ErrEmptyForest is thrown when the LSHForest is queried before any
+	// element is inserted into it
+	ErrEmptyForest = errors.New("lshforest doesn't contain any elements")
+	// ErrIDOverflow is thrown when an element would be given the id
+	// math.MaxUint, which is reserved so the next id never wraps to 0
+	ErrIDOverflow = errors.New("element ids must be below math.MaxUint")
+	// ErrNonFinite is thrown when a vector has a NaN or infinite component.
+	// It's wrapped by a NonFiniteError, which is matched by errors.Is
+	ErrNonFinite = errors.New("vector's components must be finite")
 )
 
-// New constructs an LSHForest struct for cosine similarity. l := the
+// NonFiniteError is thrown when the component at Index of a vector is NaN or
+// infinite. InBatch is set if the vector was one of those passed to InsertAll,
+// and Vector is then its position among them. Otherwise Vector is 0
+type NonFiniteError struct {
+	Index   uint
+	Vector  uint
+	InBatch bool
+}
+
+func (e NonFiniteError) Error() string {
+	if e.InBatch {
+		return fmt.Sprintf("component %d of vector %d is NaN or infinite: %v",
+			e.Index, e.Vector, ErrNonFinite)
+	}
+	return fmt.Sprintf("component %d is NaN or infinite: %v", e.Index,
+		ErrNonFinite)
+}
+
+// inBatch sets the position of the vector of err, if it's a NonFiniteError, to
+// i of a batch
+func inBatch(err error, i int) error {
+	if e, ok := err.(NonFiniteError); ok {
+		e.Vector, e.InBatch = uint(i), true
+		return e
+	}
+	return err
+}
+
+// Unwrap returns ErrNonFinite
+func (e NonFiniteError) Unwrap() error {
+	return ErrNonFinite
+}
+
+// New constructs an LSHForest struct for the given similarity metric. l := the
 // number of trees in the forest of LSHForest. maxK := the maximum number of
 // hash functions. The larger maxK is, the more accurate LSHForest is and the
-// more space LSHForest takes up. dim := the dimension of the input vectors
-func New(l, maxK, dim, metric uint) *LSHForest {
-	var trees []lshtree.LSHTree
+// more space LSHForest takes up. dim := the dimension of the input vectors.
+// With Jaccard, a vector is the set of the indices of its non-zero components.
+// With Euclidean, the bucket width of the hashers is set by WithBucketWidth.
+// Values are interface{}, NewTyped constructs an LSHForest whose values have a
+// given type
+func New(l, maxK, dim, metric uint, opts ...Option) *LSHForest[interface{}] {
+	return NewTyped[interface{}](l, maxK, dim, metric, opts...)
+}
+
+// NewTyped constructs an LSHForest struct, as New does, whose values have type
+// V. Its queries return values of type V
+func NewTyped[V any](l, maxK, dim, metric uint, opts ...Option) *LSHForest[V] {
+	c := newConfig(opts)
+	seeds := c.treeSeeds(l)
+	var trees []lshtree.LSHTree[V]
 	var hashers []hash.Hasher
 	for i := uint(0); i < l; i++ {
-		trie := lshtree.NewTrie()
-		trees = append(trees, &trie)
-		if metric == Cosine {
-			hashers = append(hashers, hash.NewOnline(maxK, dim))
-		} else {
+		trees = append(trees, newTree[V](c.backend))
+		switch metric {
+		case Cosine:
+			hashers = append(hashers, hash.NewOnline(maxK, dim,
+				hash.WithSeed(seeds[i])))
+		case Jaccard:
+			hashers = append(hashers, hash.NewMinhash(maxK,
+				hash.WithSeed(seeds[i])))
+		case Euclidean:
+			hashers = append(hashers, hash.NewPStable(maxK, dim, c.width,
+				hash.WithSeed(seeds[i])))
+		default:
 			panic("lshforest invalid hasher")
 		}
 	}
-	return &LSHForest{trees: trees, hashers: hashers, vecDim: dim}
+	return &LSHForest[V]{trees: trees, hashers: hashers,
+		similarity: similarity(metric), seeds: seeds, metric: metric,
+		backend: c.backend, maxK: maxK, vecDim: dim, entries: make(map[uint]entry[V]),
+		codec: c.codec, multiplier: c.multiplier, probes: c.probes,
+		concurrent: c.concurrent, zeroCopy: c.zeroCopy, workers: c.workers,
+		treeLocks: make([]sync.RWMutex, l)}
+}
+
+func newTree[V any](backend uint) lshtree.LSHTree[V] {
+	switch backend {
+	case TrieBackend:
+		trie := lshtree.NewTrie[V]()
+		return &trie
+	case SortedBackend:
+		sorted := lshtree.NewSorted[V]()
+		return &sorted
+	}
+	panic("lshforest invalid backend")
+}
+
+func similarity(metric uint) func(point, point) float64 {
+	switch metric {
+	case Jaccard:
+		return forms{jaccard[float64, float64], jaccard[float64, float32],
+			jaccard[float32, float32], sparseJaccard, mixedJaccard[float64],
+			mixedJaccard[float32]}.similarity
+	case Euclidean:
+		return forms{euclidean[float64, float64], euclidean[float64, float32],
+			euclidean[float32, float32], sparseEuclidean,
+			mixedEuclidean[float64], mixedEuclidean[float32]}.similarity
+	}
+	return forms{cosine, denseCosine[float64, float32],
+		denseCosine[float32, float32], sparseCosine, mixedCosine[float64],
+		mixedCosine[float32]}.similarity
+}
+
+// Seeds returns the seed of each tree's hasher. Passing them to New with
+// WithTreeSeeds, along with the same parameters, reconstructs the hashers
+func (f *LSHForest[V]) Seeds() []int64 {
+	return append([]int64{}, f.seeds...)
 }
 
-func magnitude(vector *[]float64) float64 {
+func magnitude[F hash.Float](vector *[]F) float64 {
 	var magnitude float64
 	for _, element := range *vector {
-		magnitude += math.Pow(element, 2)
+		magnitude += math.Pow(float64(element), 2)
 	}
 	return math.Sqrt(magnitude)
 }
 
-// InsertAll added each vector and value to the LSH Forest
-func (f *LSHForest) InsertAll(vectors *[][]float64, values *[]interface{}) error {
+// InsertAll adds each vector and value to the LSH Forest. Nothing is inserted
+// if any vector is invalid. The ids of the elements are consecutive
+func (f *LSHForest[V]) InsertAll(vectors *[][]float64, values *[]V) error {
+	_, err := f.InsertAllID(vectors, values)
+	return err
+}
+
+// InsertAllID adds each vector and value to the LSH Forest, as InsertAll does,
+// and returns the id of the first element. The ith element has the id first +
+// i. If there are no vectors, first is the id the next element will be given
+func (f *LSHForest[V]) InsertAllID(vectors *[][]float64,
+	values *[]V) (first uint, err error) {
 	if len(*vectors) != len(*values) {
-		return errors.New("len(*vectors) != len(*values)")
+		return 0, errors.New("len(*vectors) != len(*values)")
 	}
+	entries := make([]entry[V], len(*vectors))
 	for i := range *vectors {
-		if err := f.Insert(&(*vectors)[i], (*values)[i]); err != nil {
+		entries[i] = newEntry(point{dense: &(*vectors)[i]}, (*values)[i])
+	}
+	return f.insertAll(entries)
+}
+
+// insertAll inserts the entries with consecutive ids and returns the first
+func (f *LSHForest[V]) insertAll(entries []entry[V]) (uint, error) {
+	for i, e := range entries {
+		if err := f.checkPoint(e.point()); err != nil {
+			return 0, inBatch(err, i)
+		}
+	}
+	f.beginWrite()
+	defer f.endWrite()
+	f.lock()
+	first, err := reserveIDs(&f.nextID, uint(len(entries)))
+	if err != nil {
+		f.unlock()
+		return 0, err
+	}
+	if !f.zeroCopy {
+		for i := range entries {
+			entries[i].setPoint(f.arenas.own(entries[i].point()))
+		}
+	}
+	f.unlock()
+
+	hashes := make([][]hash.Signature, len(entries))
+	f.parallel(len(entries), hashBatch, func(i int) {
+		hashes[i] = make([]hash.Signature, len(f.hashers))
+		for j := range f.hashers {
+			hashes[i][j] = f.hash(j, entries[i].point())
+		}
+	})
+	f.parallel(len(f.trees), 1, func(i int) {
+		elements := make([]lshtree.Element[V], len(entries))
+		for j, e := range entries {
+			elements[j] = e.element(first+uint(j), hashes[j][i])
+		}
+		if bulk, ok := f.trees[i].(interface {
+			InsertAll([]lshtree.Element[V]) error
+		}); ok {
+			f.lockTree(i)
+			bulk.InsertAll(elements)
+			f.unlockTree(i)
+			return
+		}
+		for _, element := range elements {
+			f.lockTree(i)
+			f.trees[i].Insert(element)
+			f.unlockTree(i)
+		}
+	})
+
+	f.lock()
+	for i, e := range entries {
+		e.hashes = hashes[i]
+		f.entries[first+uint(i)] = e
+	}
+	f.unlock()
+	return first, nil
+}
+
+// Insert puts the vector into the LSHForest. Elements are given ids in
+// insertion order, so the nth element inserted, counting from 0, has the id n
+func (f *LSHForest[V]) Insert(vector *[]float64, value V) error {
+	_, err := f.InsertID(vector, value)
+	return err
+}
+
+// InsertID puts the vector into the LSHForest, as Insert does, and returns the
+// id it's given
+func (f *LSHForest[V]) InsertID(vector *[]float64, value V) (uint, error) {
+	return f.insertNext(newEntry(point{dense: vector}, value))
+}
+
+// insertNext inserts the entry with the next id and returns the id
+func (f *LSHForest[V]) insertNext(e entry[V]) (uint, error) {
+	if err := f.checkPoint(e.point()); err != nil {
+		return 0, err
+	}
+	f.beginWrite()
+	defer f.endWrite()
+	f.lock()
+	id, err := reserveIDs(&f.nextID, 1)
+	f.unlock()
+	if err != nil {
+		return 0, err
+	}
+	f.insert(id, e)
+	return id, nil
+}
+
+// reserveIDs returns the first of n consecutive ids starting at *nextID and
+// moves *nextID past them, or ErrIDOverflow if they'd reach math.MaxUint
+func reserveIDs(nextID *uint, n uint) (uint, error) {
+	if n > math.MaxUint-*nextID {
+		return 0, ErrIDOverflow
+	}
+	first := *nextID
+	*nextID += n
+	return first, nil
+}
+
+// InsertWithID puts the vector into the LSHForest with the given id instead of
+// the next id in insertion order. Elements inserted afterwards with Insert are
+// given ids above id. id must be below math.MaxUint
+func (f *LSHForest[V]) InsertWithID(id uint, vector *[]float64,
+	value V) error {
+	if err := f.checkVector(vector); err != nil {
+		return err
+	}
+	if id == math.MaxUint {
+		return ErrIDOverflow
+	}
+	f.beginWrite()
+	defer f.endWrite()
+	f.lock()
+	if _, ok := f.entries[id]; ok || f.pending[id] {
+		f.unlock()
+		return ErrIDExists
+	}
+	f.pend(id)
+	if id >= f.nextID {
+		f.nextID = id + 1
+	}
+	f.unlock()
+	f.insert(id, newEntry(point{dense: vector}, value))
+	f.unpend(id)
+	return nil
+}
+
+// pend marks id as being inserted, so InsertWithID can't insert it too until
+// unpend is called. f.mu must be held
+func (f *LSHForest[V]) pend(id uint) {
+	if f.pending == nil {
+		f.pending = make(map[uint]bool)
+	}
+	f.pending[id] = true
+}
+
+func (f *LSHForest[V]) unpend(id uint) {
+	f.lock()
+	delete(f.pending, id)
+	f.unlock()
+}
+
+// insert puts the element into each tree and then makes it visible to Delete
+func (f *LSHForest[V]) insert(id uint, e entry[V]) {
+	if !f.zeroCopy {
+		f.lock()
+		e.setPoint(f.arenas.own(e.point()))
+		f.unlock()
+	}
+	e.hashes = make([]hash.Signature, len(f.trees))
+	f.parallel(len(f.trees), 1, func(i int) {
+		e.hashes[i] = f.hash(i, e.point())
+		element := e.element(id, e.hashes[i])
+		f.lockTree(i)
+		f.trees[i].Insert(element)
+		f.unlockTree(i)
+	})
+	f.lock()
+	f.entries[id] = e
+	f.unlock()
+}
+
+// Delete removes the element with the given id from the LSHForest
+func (f *LSHForest[V]) Delete(id uint) error {
+	f.beginWrite()
+	defer f.endWrite()
+	return f.delete(id)
+}
+
+func (f *LSHForest[V]) delete(id uint) error {
+	entry, err := f.take(id, false)
+	if err != nil {
+		return err
+	}
+	return f.deleteTrees(id, entry)
+}
+
+// take removes the entry with the given id, so no other call finds it, and
+// marks id as being inserted if pend is set
+func (f *LSHForest[V]) take(id uint, pend bool) (entry[V], error) {
+	f.lock()
+	defer f.unlock()
+	entry, ok := f.entries[id]
+	if !ok {
+		return entry, ErrNotFound
+	}
+	delete(f.entries, id)
+	if pend {
+		f.pend(id)
+	}
+	return entry, nil
+}
+
+// deleteTrees removes the element with the given id and entry from each tree
+func (f *LSHForest[V]) deleteTrees(id uint, entry entry[V]) error {
+	errs := make([]error, len(f.trees))
+	f.parallel(len(f.trees), 1, func(i int) {
+		element := entry.element(id, entry.hashes[i])
+		f.lockTree(i)
+		errs[i] = f.trees[i].Delete(element)
+		f.unlockTree(i)
+	})
+	for _, err := range errs {
+		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// Insert puts the vector into the LSHForest
-func (f *LSHForest) Insert(vector *[]float64, value interface{}) error {
+// Update replaces the vector and value of the element with the given id. Until
+// it returns, the element isn't found by Get, Delete or another Update, and
+// InsertWithID can't insert its id
+func (f *LSHForest[V]) Update(id uint, vector *[]float64, value V) error {
 	if err := f.checkVector(vector); err != nil {
 		return err
 	}
-	for i, tree := range f.trees {
-		tree.Insert(lshtree.NewElement(f.hashers[i].Hash(vector), vector, value))
+	f.beginWrite()
+	defer f.endWrite()
+	old, err := f.take(id, true)
+	if err != nil {
+		return err
+	}
+	defer f.unpend(id)
+	if err := f.deleteTrees(id, old); err != nil {
+		return err
 	}
+	f.insert(id, newEntry(point{dense: vector}, value))
 	return nil
 }
 
-func (f *LSHForest) checkVector(vector *[]float64) error {
-	if magnitude(vector) == 0 {
+// Get returns the value and vector of the element with the given id. The
+// vector is nil if the element was inserted with InsertFloat32 or InsertSparse.
+// Unless the LSHForest was constructed WithZeroCopy, it's the LSHForest's copy,
+// which shouldn't be modified
+func (f *LSHForest[V]) Get(id uint) (V, *[]float64, error) {
+	f.lock()
+	defer f.unlock()
+	entry, ok := f.entries[id]
+	if !ok {
+		var zero V
+		return zero, nil, ErrNotFound
+	}
+	return entry.Value, entry.Vector, nil
+}
+
+// Contains returns whether an element has the given id
+func (f *LSHForest[V]) Contains(id uint) bool {
+	f.lock()
+	defer f.unlock()
+	_, ok := f.entries[id]
+	return ok
+}
+
+// Len returns the number of elements in the LSHForest
+func (f *LSHForest[V]) Len() uint {
+	f.lock()
+	defer f.unlock()
+	return uint(len(f.entries))
+}
+
+// IDs returns the id of every element in the LSHForest in ascending order
+func (f *LSHForest[V]) IDs() []uint {
+	f.lock()
+	defer f.unlock()
+	return sortedIDs(f.entries)
+}
+
+func sortedIDs[V any](entries map[uint]entry[V]) []uint {
+	ids := make([]uint, 0, len(entries))
+	for id := range entries {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (f *LSHForest[V]) checkVector(vector *[]float64) error {
+	return checkVector(vector, f.metric, f.vecDim)
+}
+
+func (f *LSHForest[V]) checkPoint(p point) error {
+	return checkPoint(p, f.metric, f.vecDim)
+}
+
+// checkVector returns an error if vector can't be compared with metric
+// against vectors of dimension dim
+func checkVector[F hash.Float](vector *[]F, metric, dim uint) error {
+	for i, x := range *vector {
+		if err := checkFinite(float64(x), uint(i)); err != nil {
+			return err
+		}
+	}
+	if metric != Euclidean && magnitude(vector) == 0 {
 		return ErrNonZero
 	}
-	if len(*vector) != int(f.vecDim) {
+	if len(*vector) != int(dim) {
 		return ErrEqDim
 	}
 	return nil
 }
 
-// Query returns a list of values sorted by similarity to the query vector
-func (f *LSHForest) Query(vector *[]float64, m uint) (*[]interface{}, error) {
-	if err := f.checkVector(vector); err != nil {
+// checkFinite returns a NonFiniteError if x, the component of a vector at
+// index, is NaN or infinite
+func checkFinite(x float64, index uint) error {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return NonFiniteError{Index: index}
+	}
+	return nil
+}
+
+// Result is a value found by a query along with its id, its similarity to the
+// query vector, its vector and the number of trees it was found in. Vector32
+// or Sparse is set instead of Vector if the element was inserted with
+// InsertFloat32 or InsertSparse
+type Result[V any] struct {
+	ID         uint
+	Value      V
+	Similarity float64
+	Vector     *[]float64
+	Vector32   *[]float32
+	Sparse     *sparse.Vector
+	Trees      uint
+}
+
+func (f *LSHForest[V]) checkQuery(query point) error {
+	if err := f.checkPoint(query); err != nil {
+		return err
+	}
+	f.lock()
+	defer f.unlock()
+	if len(f.entries) == 0 {
+		return ErrEmptyForest
+	}
+	return nil
+}
+
+// QueryStats is the work done by a query
+type QueryStats struct {
+	// Candidates is the number of distinct elements which were compared with
+	// the query vector
+	Candidates uint
+	// Partial is true if the query's context was done before the query
+	// finished, so its results are the best of the candidates found by then
+	Partial bool
+	// Probes is the number of subtrees visited by flipping a bit of the
+	// query's hash, across every tree
+	Probes uint
+	// Collected is the number of candidates an LSHForest collected from its
+	// trees, counting an element once for each tree it was found in, which
+	// WithMultiplier's c * l is compared with
+	Collected uint
+}
+
+// Query returns a list of values sorted by similarity to the query vector.
+// Fewer than m values are returned if fewer than m elements are found
+func (f *LSHForest[V]) Query(vector *[]float64, m uint,
+	opts ...QueryOption) (*[]V, error) {
+	return f.QueryContext(context.Background(), vector, m, opts...)
+}
+
+// QueryContext is Query which stops once ctx is done. It returns ctx.Err(),
+// or the best results found so far if it's passed Partial()
+func (f *LSHForest[V]) QueryContext(ctx context.Context, vector *[]float64,
+	m uint, opts ...QueryOption) (*[]V, error) {
+	results, err := f.QueryResultsContext(ctx, vector, m, opts...)
+	if err != nil {
 		return nil, err
 	}
+	return resultValues(results), nil
+}
+
+func resultValues[V any](results *[]Result[V]) *[]V {
+	var values []V
+	for _, result := range *results {
+		values = append(values, result.Value)
+	}
+	return &values
+}
 
-	var nodes []*lshtree.Node
-	var depths []uint
-	for i, tree := range f.trees {
-		node, depth := tree.Descend(f.hashers[i].Hash(vector))
-		nodes = append(nodes, node)
-		depths = append(depths, depth)
+// QueryIDs returns a list of the ids of the elements found, sorted by
+// similarity to the query vector, as Query does
+func (f *LSHForest[V]) QueryIDs(vector *[]float64, m uint,
+	opts ...QueryOption) (*[]uint, error) {
+	results, err := f.QueryResults(vector, m, opts...)
+	if err != nil {
+		return nil, err
 	}
+	return resultIDs(results), nil
+}
+
+func resultIDs[V any](results *[]Result[V]) *[]uint {
+	ids := make([]uint, len(*results))
+	for i, result := range *results {
+		ids[i] = result.ID
+	}
+	return &ids
+}
+
+// QueryResults returns a list of results sorted by similarity to the query
+// vector. Fewer than m results are returned if fewer than m elements are found.
+// The trees are ascended until m elements are found, along with c * l
+// candidates, counting an element once for each tree it's found in, if a
+// candidate multiplier c is set by WithMultiplier or Multiplier
+func (f *LSHForest[V]) QueryResults(vector *[]float64, m uint,
+	opts ...QueryOption) (*[]Result[V], error) {
+	return f.QueryResultsContext(context.Background(), vector, m, opts...)
+}
 
-	candidates := f.syncAscend(&nodes, &depths, m)
-	elementsSort(candidates, vector)
-	elements := (*candidates)[:m]
+// QueryResultsContext is QueryResults which stops once ctx is done. It returns
+// ctx.Err(), or the best results found so far if it's passed Partial()
+func (f *LSHForest[V]) QueryResultsContext(ctx context.Context,
+	vector *[]float64, m uint, opts ...QueryOption) (*[]Result[V], error) {
+	return f.queryResults(ctx, point{dense: vector}, m, opts)
+}
 
-	var values []interface{}
-	for _, element := range elements {
-		values = append(values, element.Value)
+func (f *LSHForest[V]) queryResults(ctx context.Context, query point, m uint,
+	opts []QueryOption) (*[]Result[V], error) {
+	if err := f.checkQuery(query); err != nil {
+		return nil, err
 	}
-	return &values, nil
+	c := f.queryConfig(opts)
+
+	l := uint(len(f.trees))
+	candidates, trees, err := f.candidates(ctx, c, query,
+		func(candidates *[]lshtree.Element[V], collected uint) bool {
+			return collected >= c.multiplier*l &&
+				uint(len(*candidates)) >= m
+		})
+	return f.rank(ctx, c, query, candidates, trees, err, m, math.Inf(-1))
+}
+
+// QueryRadius returns a list of results, sorted by similarity to the query
+// vector, of every element found with a similarity of at least minSimilarity.
+// The trees are ascended until a level adds candidates of which none are at
+// least minSimilarity similar to the query vector
+func (f *LSHForest[V]) QueryRadius(vector *[]float64, minSimilarity float64,
+	opts ...QueryOption) (*[]Result[V], error) {
+	return f.QueryRadiusContext(context.Background(), vector, minSimilarity,
+		opts...)
 }
 
-func elementsSort(elements *[]lshtree.Element, query *[]float64) {
-	ids := make(map[lshtree.Element]uint, len(*elements))
-	for i, element := range *elements {
-		ids[element] = uint(i)
+// QueryRadiusContext is QueryRadius which stops once ctx is done. It returns
+// ctx.Err(), or the results found so far if it's passed Partial()
+func (f *LSHForest[V]) QueryRadiusContext(ctx context.Context,
+	vector *[]float64, minSimilarity float64,
+	opts ...QueryOption) (*[]Result[V], error) {
+	query := point{dense: vector}
+	if err := f.checkQuery(query); err != nil {
+		return nil, err
 	}
+	c := f.queryConfig(opts)
 
-	similarities := make(map[uint]float64, len(*elements))
-	for element, id := range ids {
-		similarity, err := cosine_similarity.Cosine(*query, *element.Vector)
-		if err != nil {
-			panic("lshforest elementsSort(): vector has magnitude of zero")
+	checked := 0 // the number of candidates compared against minSimilarity
+	candidates, trees, err := f.candidates(ctx, c, query,
+		func(candidates *[]lshtree.Element[V], _ uint) bool {
+			added := (*candidates)[checked:]
+			checked = len(*candidates)
+			if len(added) == 0 {
+				return false
+			}
+			for _, element := range added {
+				if f.similarity(query, elementPoint(element)) >=
+					minSimilarity {
+					return false
+				}
+			}
+			return true
+		})
+	return f.rank(ctx, c, query, candidates, trees, err,
+		uint(len(*candidates)), minSimilarity)
+}
+
+// rank returns the results for the m candidates of a query most similar to
+// the query vector with a similarity of at least minSimilarity. err is the
+// error candidates returned. If ctx is done and the query is partial, the
+// results are ranked from the candidates found so far instead of returning
+// ctx.Err(). Only the first rankBatch of them are ranked if ctx was done while
+// they were found, so the query overruns ctx's deadline by a batch at most
+func (f *LSHForest[V]) rank(ctx context.Context, c queryConfig,
+	query point, candidates *[]lshtree.Element[V], trees map[uint]uint,
+	err error, m uint, minSimilarity float64) (*[]Result[V], error) {
+	if err != nil {
+		if !c.partial {
+			return nil, err
+		}
+		// rank the candidates found before ctx was done. The first share the
+		// longest prefixes with the query's hashes
+		if len(*candidates) > rankBatch {
+			first := (*candidates)[:rankBatch]
+			candidates = &first
 		}
-		similarities[id] = similarity
+		ctx = context.Background()
+	}
+	top, similarities, scored, rankErr := topElements(ctx, candidates,
+		score[V](f.similarity, query), m, minSimilarity)
+	if rankErr != nil && !c.partial {
+		return nil, rankErr
 	}
+	c.record(scored, err != nil || rankErr != nil)
+	c.recordCollected(trees)
+	return newResults(top, similarities, trees), nil
+}
+
+// queryConfig applies opts to the forest's defaults for queries
+func (f *LSHForest[V]) queryConfig(opts []QueryOption) queryConfig {
+	return newQueryConfig(queryConfig{multiplier: f.multiplier,
+		probes: f.probes}, opts)
+}
+
+// candidates descends and probes the trees for the query vector and then
+// ascends them with syncAscend until done returns true or ctx is done. A
+// sparse query vector isn't probed
+func (f *LSHForest[V]) candidates(ctx context.Context, c queryConfig,
+	query point, done func(*[]lshtree.Element[V], uint) bool) (
+	*[]lshtree.Element[V], map[uint]uint, error) {
+	hashes := make([]hash.Signature, len(f.hashers))
+	margins := make([][]float64, len(f.hashers))
+	f.parallel(len(f.hashers), 1, func(i int) {
+		prober, ok := f.hashers[i].(hash.Float32Prober)
+		switch {
+		case !ok || c.probes == 0 || query.sparse != nil:
+			hashes[i] = f.hash(i, query)
+		case query.dense32 != nil:
+			hashes[i], margins[i] = prober.HashMargins32(query.dense32)
+		default:
+			hashes[i], margins[i] = prober.HashMargins(query.dense)
+		}
+	})
+	f.rlockTrees()
+	defer f.runlockTrees()
+	ascents := f.descend(&hashes)
+	probes := f.probe(&hashes, margins, ascents, c.probes)
+	c.recordProbes(len(probes))
+	return f.syncAscend(ctx, append(ascents, probes...), done)
+}
+
+// ascent is a subtree of a tree which syncAscend collects candidates from at
+// depth, and then ascends from, until it's collected at floor
+type ascent[V any] struct {
+	cursor lshtree.Cursor[V]
+	depth  uint
+	floor  uint
+	tree   int
+}
 
-	sort.Slice(*elements, func(i, j int) bool {
-		return similarities[ids[(*elements)[i]]] >
-			similarities[ids[(*elements)[j]]]
+// descend returns the subtree each tree descends to for its hash
+func (f *LSHForest[V]) descend(hashes *[]hash.Signature) []ascent[V] {
+	ascents := make([]ascent[V], len(f.trees))
+	f.parallel(len(f.trees), 1, func(i int) {
+		cursor, depth := f.trees[i].Descend((*hashes)[i])
+		ascents[i] = ascent[V]{cursor: cursor, depth: depth, tree: i}
 	})
+	return ascents
 }
 
-func maxUint(slice *[]uint) uint {
-	var max uint
-	for _, num := range *slice {
-		if num > max {
-			max = num
+// probe returns the subtrees reached by flipping each of the n bits of each
+// tree's hash with the smallest margins. Only bits above the subtree the hash
+// descends to are flipped, as the subtree contains every other flip. The
+// flipped bit is a mismatch, so a probe whose prefix is depth bits long is
+// ascended in lockstep with the hash's own subtrees of depth - 1, and never
+// ahead of its tree's. It stops at the flipped bit, where it would join the
+// hash's own path
+func (f *LSHForest[V]) probe(hashes *[]hash.Signature, margins [][]float64,
+	ascents []ascent[V], n uint) []ascent[V] {
+	probes := make([][]ascent[V], len(f.trees))
+	f.parallel(len(f.trees), 1, func(i int) {
+		if margins[i] == nil || ascents[i].cursor == nil {
+			return
+		}
+		bits := make([]uint, ascents[i].depth)
+		for bit := range bits {
+			bits[bit] = uint(bit)
+		}
+		sort.SliceStable(bits, func(a, b int) bool {
+			return margins[i][bits[a]] < margins[i][bits[b]]
+		})
+		if uint(len(bits)) > n {
+			bits = bits[:n]
+		}
+		for _, bit := range bits {
+			cursor, depth, ok := f.trees[i].Probe((*hashes)[i], bit)
+			if !ok {
+				continue
+			}
+			for depth > ascents[i].depth && depth > bit+1 {
+				cursor, _ = cursor.Ascend()
+				depth--
+			}
+			probes[i] = append(probes[i], ascent[V]{cursor: cursor,
+				depth: depth - 1, floor: bit, tree: i})
 		}
+	})
+	var flat []ascent[V]
+	for _, p := range probes {
+		flat = append(flat, p...)
+	}
+	return flat
+}
+
+// newResults constructs the results for the sorted candidates
+func newResults[V any](candidates *[]lshtree.Element[V],
+	similarities *[]float64, trees map[uint]uint) *[]Result[V] {
+	results := []Result[V]{}
+	for i, element := range *candidates {
+		results = append(results, Result[V]{ID: element.ID, Value: element.Value,
+			Similarity: (*similarities)[i], Vector: element.Vector,
+			Vector32: element.Vector32, Sparse: element.Sparse,
+			Trees: trees[element.ID]})
 	}
-	return max
+	return &results
 }
 
-func distinctElements(elements *[]lshtree.Element) uint {
-	count := make(map[lshtree.Element]uint, len(*elements))
-	for _, element := range *elements {
-		count[element]++
+func cosine(v1, v2 *[]float64) float64 {
+	similarity, err := cosine_similarity.Cosine(*v1, *v2)
+	if err != nil {
+		panic("lshforest cosine(): vector has magnitude of zero")
 	}
-	return uint(len(count))
+	return similarity
 }
 
-func unionElements(e1, e2 *[]lshtree.Element) {
-	count := make(map[lshtree.Element]uint, len(*e1))
-	for _, element := range *e1 {
-		count[element]++
+// denseCosine is cosine for vectors of any float type
+func denseCosine[A, B hash.Float](v1 *[]A, v2 *[]B) float64 {
+	var dotProduct float64
+	for i := range *v1 {
+		dotProduct += float64((*v1)[i]) * float64((*v2)[i])
+	}
+	magnitudes := magnitude(v1) * magnitude(v2)
+	if magnitudes == 0 {
+		panic("lshforest cosine(): vector has magnitude of zero")
 	}
-	for _, element := range *e2 {
-		if _, ok := count[element]; !ok {
-			*e1 = append(*e1, element)
+	return dotProduct / magnitudes
+}
+
+func jaccard[A, B hash.Float](v1 *[]A, v2 *[]B) float64 {
+	var intersection, union float64
+	for i := range *v1 {
+		in1, in2 := (*v1)[i] != 0, (*v2)[i] != 0
+		if in1 && in2 {
+			intersection++
+		}
+		if in1 || in2 {
+			union++
 		}
 	}
+	if union == 0 {
+		panic("lshforest jaccard(): vector has magnitude of zero")
+	}
+	return intersection / union
+}
+
+// euclidean returns 1 / (1 + the euclidean distance between v1 and v2), so
+// closer vectors are more similar
+func euclidean[A, B hash.Float](v1 *[]A, v2 *[]B) float64 {
+	var distance float64
+	for i := range *v1 {
+		d := float64((*v1)[i]) - float64((*v2)[i])
+		distance += d * d
+	}
+	return 1 / (1 + math.Sqrt(distance))
 }
 
-func (f *LSHForest) syncAscend(nodes *[]*lshtree.Node, depths *[]uint, m uint) *[]lshtree.Element {
-	x := maxUint(depths)
-	var candidates []lshtree.Element
-	l, c := len(f.trees), 0
-	for x > 0 && (len(candidates) < c*l ||
-		distinctElements(&candidates) < m) {
-		for i := 0; i < l; i++ {
-			if (*depths)[i] == x {
-				descendants := (*nodes)[i].Decendants()
-				var descendantElements []lshtree.Element
-				for _, nodes := range descendants {
-					descendantElements = append(descendantElements, nodes.Elements...)
+// syncAscend ascends the trees in lockstep, collecting candidates, until done
+// returns true. done is passed the distinct candidates and the number
+// collected, which counts a candidate once for each tree it's found in. It
+// returns the candidates for a query along with the number of
+// trees each candidate, by id, was found in. If ctx is done first, it returns
+// the candidates collected so far and ctx.Err(). ctx is checked every
+// rankBatch elements walked, so collection stops within a batch of ctx being
+// done
+func (f *LSHForest[V]) syncAscend(ctx context.Context, ascents []ascent[V],
+	done func(*[]lshtree.Element[V], uint) bool) (*[]lshtree.Element[V],
+	map[uint]uint, error) {
+	var x uint
+	for _, a := range ascents {
+		if a.depth > x {
+			x = a.depth
+		}
+	}
+	var candidates []lshtree.Element[V]
+	trees := make(map[uint]uint)
+	found := make(map[[2]uint]bool) // whether element id was found in tree i
+	var collected uint
+	walked := 0 // the number of elements walked, to check ctx in batches
+	var err error
+	for !done(&candidates, collected) {
+		for i := range ascents {
+			a := &ascents[i]
+			if a.depth == x && a.cursor != nil {
+				if err = ctx.Err(); err != nil {
+					return &candidates, trees, err
+				}
+				// a subtree may hold every element, so ctx is checked while
+				// it's walked too
+				a.cursor.Walk(func(element lshtree.Element[V]) bool {
+					if walked++; walked%rankBatch == 0 {
+						if err = ctx.Err(); err != nil {
+							return false
+						}
+					}
+					key := [2]uint{element.ID, uint(a.tree)}
+					if found[key] {
+						return true
+					}
+					found[key] = true
+					// the same element is a different lshtree.Element in
+					// each tree, so candidates are distinct by id
+					if trees[element.ID] == 0 {
+						candidates = append(candidates, element)
+					}
+					trees[element.ID]++
+					collected++
+					return true
+				})
+				if err != nil {
+					return &candidates, trees, err
+				}
+				if a.depth == a.floor {
+					a.cursor = nil
+				} else {
+					a.cursor, _ = a.cursor.Ascend()
+					a.depth--
 				}
-				unionElements(&candidates, &descendantElements)
-				(*nodes)[i] = (*nodes)[i].Parent
-				(*depths)[i]--
 			}
 		}
+		if x == 0 {
+			break
+		}
 		x--
 	}
-	return &candidates
+	return &candidates, trees, nil
 }
@@ -1,6 +1,36 @@
 package lshforest
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/justinfargnoli/lshforest/pkg/sparse"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func randomVector(r *rand.Rand, dim int) []float64 {
+	vector := make([]float64, dim)
+	for i := range vector {
+		vector[i] = r.NormFloat64()
+	}
+	return vector
+}
+
+// randomVectors returns n random vectors of dimension dim and their values,
+// which are their indices
+func randomVectors(r *rand.Rand, n, dim int) ([][]float64, []interface{}) {
+	var vectors [][]float64
+	var values []interface{}
+	for i := 0; i < n; i++ {
+		vectors = append(vectors, randomVector(r, dim))
+		values = append(values, i)
+	}
+	return vectors, values
+}
 
 func TestNewDefault(t *testing.T) {
 	_ = NewDefault(300, Cosine)
@@ -8,7 +38,7 @@ func TestNewDefault(t *testing.T) {
 	_ = NewDefault(100, Cosine)
 }
 
-func TestNewDefaultPanicJaccard(t *testing.T) {
+func TestNewDefaultPanicInvalidMetric(t *testing.T) {
 	defer func() {
 		if r := recover(); r == nil {
 			t.FailNow()
@@ -17,6 +47,10 @@ func TestNewDefaultPanicJaccard(t *testing.T) {
 	_ = NewDefault(1, 100)
 }
 
+func TestNewDefaultJaccard(t *testing.T) {
+	_ = NewDefault(300, Jaccard)
+}
+
 func TestInsert(t *testing.T) {
 	lshforest := NewDefault(3, Cosine)
 
@@ -63,7 +97,7 @@ func TestInsertAll(t *testing.T) {
 	}
 }
 
-func insertAll(t *testing.T) *LSHForest {
+func insertAll(t *testing.T) *LSHForest[interface{}] {
 	vectors := [][]float64{
 		{1, 2, 3},
 		{1.1, 4, -3},
@@ -112,3 +146,733 @@ func TestQuery(t *testing.T) {
 		t.Fatalf("Expected \"a\" | got (%v)", (*value)[0].(string))
 	}
 }
+
+func TestNonFinite(t *testing.T) {
+	lshforest := NewDefault(3, Cosine)
+	valid := []float64{1, 2, 3}
+	if err := lshforest.Insert(&valid, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i, x := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		vector := []float64{1, 2, 3}
+		vector[i] = x
+		expected := NonFiniteError{Index: uint(i)}
+		if err := lshforest.Insert(&vector, 1); err != expected {
+			t.Fatalf("expected: (%v) | got: (%v)", expected, err)
+		}
+		err := lshforest.InsertAll(&[][]float64{valid, vector},
+			&[]interface{}{1, 2})
+		if !errors.Is(err, ErrNonFinite) ||
+			err != (NonFiniteError{Index: uint(i), Vector: 1, InBatch: true}) {
+			t.Fatalf("expected: (%v) | got: (%v)", ErrNonFinite, err)
+		}
+		// the first vector of a batch isn't mistaken for a single vector
+		err = lshforest.InsertAll(&[][]float64{vector}, &[]interface{}{1})
+		if err == expected || err != (NonFiniteError{Index: uint(i),
+			InBatch: true}) {
+			t.Fatalf("expected: (%v) | got: (%v)", ErrNonFinite, err)
+		}
+		if _, err := lshforest.Query(&vector, 1); err != expected {
+			t.Fatalf("expected: (%v) | got: (%v)", expected, err)
+		}
+		vector32 := []float32{1, 2, 3}
+		vector32[i] = float32(x)
+		if err := lshforest.InsertFloat32(&vector32, 1); err != expected {
+			t.Fatalf("expected: (%v) | got: (%v)", expected, err)
+		}
+	}
+	vector, _ := sparse.New([]uint{2}, []float64{math.NaN()})
+	if _, err := lshforest.QuerySparse(vector, 1); err != (NonFiniteError{
+		Index: 2}) {
+		t.Fatalf("expected: (%v) | got: (%v)", NonFiniteError{Index: 2}, err)
+	}
+	if lshforest.Len() != 1 {
+		t.Fatalf("expected: (1) | got: (%v)", lshforest.Len())
+	}
+}
+
+func TestDelete(t *testing.T) {
+	lshforest := insertAll(t)
+
+	if err := lshforest.Delete(6); err != ErrNotFound {
+		t.Fatal(err)
+	}
+	if err := lshforest.Delete(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := lshforest.Delete(3); err != ErrNotFound {
+		t.Fatal(err)
+	}
+
+	value, err := lshforest.Query(&[]float64{1, 1, 1}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range *value {
+		if v.(int) == 3 {
+			t.Fatalf("got deleted value: (%v)", *value)
+		}
+	}
+}
+
+func TestDeleteModified(t *testing.T) {
+	const dim = 8
+	r := rand.New(rand.NewSource(8))
+	vectors, values := randomVectors(r, 50, dim)
+	lshforest := New(4, 16, dim, Cosine, WithSeed(1), WithZeroCopy())
+	if err := lshforest.InsertAll(&vectors, &values); err != nil {
+		t.Fatal(err)
+	}
+	// the element is deleted by the hashes it was inserted with
+	for i := range vectors[0] {
+		vectors[0][i] = -vectors[0][i]
+	}
+	if err := lshforest.Delete(0); err != nil {
+		t.Fatal(err)
+	}
+	ids, err := lshforest.QueryIDs(&vectors[0], 50)
+	if err != nil || len(*ids) != 49 {
+		t.Fatalf("expected: (49) ids | got: (%v, %v)", ids, err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	lshforest := insertAll(t)
+
+	if err := lshforest.Update(6, &[]float64{1, 1, 1}, 6); err != ErrNotFound {
+		t.Fatal(err)
+	}
+	if err := lshforest.Update(0, &[]float64{0, 0, 0}, 6); err != ErrNonZero {
+		t.Fatal(err)
+	}
+	if err := lshforest.Update(0, &[]float64{-5, -1, 2}, 6); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := lshforest.Query(&[]float64{-5, -1, 2}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*value)[0].(int) != 6 {
+		t.Fatalf("expected: (6) | got: (%v)", (*value)[0])
+	}
+}
+
+func TestQueryJaccard(t *testing.T) {
+	vectors := [][]float64{
+		{1, 1, 1, 0, 0, 0, 0, 0},
+		{1, 1, 1, 1, 0, 0, 0, 0},
+		{0, 0, 0, 0, 1, 1, 1, 0},
+		{0, 0, 0, 1, 1, 1, 1, 1},
+		{1, 0, 1, 0, 1, 0, 1, 0},
+	}
+	values := []interface{}{0, 1, 2, 3, 4}
+	lshforest := NewDefault(8, Jaccard)
+	if err := lshforest.InsertAll(&vectors, &values); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := lshforest.Query(&[]float64{0, 0, 0, 0, 0, 0, 0, 0}, 1); err != ErrNonZero {
+		t.Fatal(err)
+	}
+
+	for i := range vectors {
+		value, err := lshforest.Query(&vectors[i], 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (*value)[0].(int) != i {
+			t.Fatalf("expected: (%v) | got: (%v)", i, (*value)[0])
+		}
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	if s := jaccard(&[]float64{1, 1, 0, 0}, &[]float64{0, 2, 3, 0}); s != 1.0/3 {
+		t.Fatalf("expected: (%v) | got: (%v)", 1.0/3, s)
+	}
+}
+
+func TestQueryResults(t *testing.T) {
+	lshforest := insertAll(t)
+
+	if _, err := lshforest.QueryResults(&[]float64{1}, 5); err != ErrEqDim {
+		t.Fatal(err)
+	}
+
+	results, err := lshforest.QueryResults(&[]float64{1, 1, 1}, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := (*results)[0]
+	if first.Value.(int) != 3 || first.Similarity < 1-1e-9 ||
+		(*first.Vector)[0] != 1 || first.Trees == 0 ||
+		first.Trees > uint(len(lshforest.trees)) {
+		t.Fatalf("got: (%+v)", first)
+	}
+	for i := 1; i < len(*results); i++ {
+		if (*results)[i].Similarity > (*results)[i-1].Similarity {
+			t.Fatalf("results aren't sorted: (%+v)", *results)
+		}
+	}
+}
+
+func TestQueryRadius(t *testing.T) {
+	lshforest := insertAll(t)
+
+	if _, err := lshforest.QueryRadius(&[]float64{0, 0, 0}, 0.5); err != ErrNonZero {
+		t.Fatal(err)
+	}
+
+	results, err := lshforest.QueryRadius(&[]float64{1, 2, 3}, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*results) == 0 || (*results)[0].Value.(int) != 0 {
+		t.Fatalf("expected: (0) first | got: (%+v)", *results)
+	}
+	for _, result := range *results {
+		if result.Similarity < 0.95 {
+			t.Fatalf("result below the cutoff: (%+v)", result)
+		}
+	}
+
+	results, err = lshforest.QueryRadius(&[]float64{1, 1, 1}, 1.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*results) != 0 {
+		t.Fatalf("expected no results | got: (%+v)", *results)
+	}
+}
+
+func TestQueryEmpty(t *testing.T) {
+	lshforest := NewDefault(3, Cosine)
+	if _, err := lshforest.Query(&[]float64{1, 1, 1}, 1); err != ErrEmptyForest {
+		t.Fatal(err)
+	}
+	if _, err := lshforest.QueryRadius(&[]float64{1, 1, 1}, 0.5); err != ErrEmptyForest {
+		t.Fatal(err)
+	}
+	if _, err := lshforest.Query(&[]float64{1}, 1); err != ErrEqDim {
+		t.Fatal(err)
+	}
+
+	if err := lshforest.Insert(&[]float64{1, 2, 3}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := lshforest.Delete(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lshforest.Query(&[]float64{1, 1, 1}, 1); err != ErrEmptyForest {
+		t.Fatal(err)
+	}
+}
+
+func TestQuerySmall(t *testing.T) {
+	lshforest := NewDefault(3, Cosine)
+	if err := lshforest.Insert(&[]float64{1, 2, 3}, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []uint{0, 1, 2, 100} {
+		value, err := lshforest.Query(&[]float64{-1, -1, -1}, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uint(len(*value)) != minUint(m, 1) {
+			t.Fatalf("m: (%v) | got: (%v)", m, *value)
+		}
+	}
+
+	if err := lshforest.Insert(&[]float64{-3, 2, 1}, 1); err != nil {
+		t.Fatal(err)
+	}
+	value, err := lshforest.Query(&[]float64{-3, 2, 1}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*value) != 2 || (*value)[0].(int) != 1 {
+		t.Fatalf("expected: ([1 0]) | got: (%v)", *value)
+	}
+}
+
+func TestQueryLargeM(t *testing.T) {
+	lshforest := insertAll(t)
+	queries := [][]float64{{1, 1, 1}, {-1, -1, -1}, {5, -3, 0.1}, {0, 0, -1}}
+	for i := range queries {
+		value, err := lshforest.Query(&queries[i], 50)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(*value) != 6 {
+			t.Fatalf("expected 6 values | got: (%v)", *value)
+		}
+	}
+}
+
+func minUint(a, b uint) uint {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func TestQueryEuclidean(t *testing.T) {
+	vectors := [][]float64{
+		{0, 0, 0},
+		{10, 10, 10},
+		{10, 10, 11},
+		{-20, 5, 3},
+		{100, 100, 100},
+	}
+	values := []interface{}{0, 1, 2, 3, 4}
+	lshforest := NewDefault(3, Euclidean, WithBucketWidth(8))
+	if err := lshforest.InsertAll(&vectors, &values); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := lshforest.QueryResults(&[]float64{10, 10, 10.2}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*results)[0].Value.(int) != 1 || (*results)[1].Value.(int) != 2 {
+		t.Fatalf("expected: (1, 2) | got: (%+v)", *results)
+	}
+	if s := (*results)[0].Similarity; math.Abs(s-1/1.2) > 1e-9 {
+		t.Fatalf("expected: (%v) | got: (%v)", 1/1.2, s)
+	}
+
+	value, err := lshforest.Query(&[]float64{0, 0, 0}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*value)[0].(int) != 0 {
+		t.Fatalf("expected: (0) | got: (%v)", (*value)[0])
+	}
+}
+
+func TestSortedBackend(t *testing.T) {
+	const dim = 12
+	r := rand.New(rand.NewSource(2))
+	vectors, values := randomVectors(r, 200, dim)
+	trie := New(6, 20, dim, Cosine, WithSeed(4))
+	sorted := New(6, 20, dim, Cosine, WithSeed(4), WithBackend(SortedBackend))
+	for _, f := range []*LSHForest[interface{}]{trie, sorted} {
+		if err := f.InsertAll(&vectors, &values); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Insert(&vectors[0], 200); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Update(1, &vectors[2], -1); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Delete(3); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 4; i < len(vectors); i++ {
+		value, err := sorted.Query(&vectors[i], 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (*value)[0] != i {
+			t.Fatalf("expected: (%v) | got: (%v)", i, *value)
+		}
+	}
+
+	// both backends find every element once they ascend to the root
+	for i := 0; i < 10; i++ {
+		query := randomVector(r, dim)
+		expected, err := trie.Query(&query, 300)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := sorted.Query(&query, 300)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sortedInts(expected), sortedInts(got)) {
+			t.Fatalf("expected: (%v) | got: (%v)", *expected, *got)
+		}
+	}
+}
+
+func sortedInts(values *[]interface{}) []int {
+	var ints []int
+	for _, v := range *values {
+		ints = append(ints, v.(int))
+	}
+	sort.Ints(ints)
+	return ints
+}
+
+func TestNewPanicInvalidBackend(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.FailNow()
+		}
+	}()
+	_ = NewDefault(3, Cosine, WithBackend(100))
+}
+
+func TestQueryStats(t *testing.T) {
+	vectors := [][]float64{{1, 0, 0}, {1, 1, 0}, {0, 1, 0}, {0, 0, 1}}
+	values := []interface{}{0, 1, 2, 3}
+	lshforest := New(3, 10, 3, Cosine, WithSeed(1))
+	if err := lshforest.InsertAll(&vectors, &values); err != nil {
+		t.Fatal(err)
+	}
+	var stats QueryStats
+	results, err := lshforest.QueryResults(&vectors[0], 1, Stats(&stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Candidates < uint(len(*results)) || stats.Candidates > 4 {
+		t.Fatalf("expected: (1 to 4) candidates | got: (%v)", stats.Candidates)
+	}
+	_, err = lshforest.QueryRadius(&vectors[0], -1, Stats(&stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Candidates != 4 {
+		t.Fatalf("expected: (4) candidates | got: (%v)", stats.Candidates)
+	}
+}
+
+// countdownContext is done once Err has been called n times
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestQueryContext(t *testing.T) {
+	const dim = 8
+	r := rand.New(rand.NewSource(6))
+	vectors, values := randomVectors(r, 300, dim)
+	lshforest := New(4, 16, dim, Cosine, WithSeed(2))
+	if err := lshforest.InsertAll(&vectors, &values); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := lshforest.QueryContext(ctx, &vectors[0], 300)
+	if err != context.Canceled {
+		t.Fatalf("expected: (%v) | got: (%v)", context.Canceled, err)
+	}
+	_, err = lshforest.QueryRadiusContext(ctx, &vectors[0], 0)
+	if err != context.Canceled {
+		t.Fatalf("expected: (%v) | got: (%v)", context.Canceled, err)
+	}
+	var stats QueryStats
+	results, err := lshforest.QueryResultsContext(ctx, &vectors[0], 300,
+		Partial(), Stats(&stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*results) != 0 || !stats.Partial {
+		t.Fatalf("expected: (0) partial results | got: (%v) (%v)", *results,
+			stats)
+	}
+
+	// the ascent is cut off after the first level of two trees, whose
+	// candidates are still ranked
+	results, err = lshforest.QueryResultsContext(
+		&countdownContext{Context: context.Background(), n: 2}, &vectors[0],
+		300, Partial(), Stats(&stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*results) == 0 || len(*results) == 300 || !stats.Partial ||
+		stats.Candidates != uint(len(*results)) {
+		t.Fatalf("expected: (1 to 299) partial results | got: (%v) (%v)",
+			len(*results), stats)
+	}
+	if (*results)[0].Value != 0 {
+		t.Fatalf("expected: (0) | got: (%v)", (*results)[0].Value)
+	}
+
+	results, err = lshforest.QueryResultsContext(context.Background(),
+		&vectors[0], 300, Partial(), Stats(&stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*results) != 300 || stats.Partial {
+		t.Fatalf("expected: (300) results | got: (%v) (%v)", len(*results),
+			stats)
+	}
+}
+
+func TestIDs(t *testing.T) {
+	vectors := [][]float64{{1, 0, 0}, {1, 1, 0}, {0, 1, 0}, {0, 0, 1}}
+	lshforest := New(3, 10, 3, Cosine, WithSeed(1))
+	if err := lshforest.Insert(&vectors[0], "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := lshforest.InsertWithID(10, &vectors[1], "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := lshforest.InsertWithID(5, &vectors[2], "c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := lshforest.InsertWithID(10, &vectors[3], "d"); err != ErrIDExists {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrIDExists, err)
+	}
+	// ids which are inserted afterwards follow the largest id
+	if err := lshforest.Insert(&vectors[3], "d"); err != nil {
+		t.Fatal(err)
+	}
+	if ids := lshforest.IDs(); !reflect.DeepEqual(ids, []uint{0, 5, 10, 11}) {
+		t.Fatalf("expected: ([0 5 10 11]) | got: (%v)", ids)
+	}
+	if lshforest.Len() != 4 || !lshforest.Contains(5) || lshforest.Contains(1) {
+		t.Fatalf("unexpected ids: (%v)", lshforest.IDs())
+	}
+
+	value, vector, err := lshforest.Get(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "b" || !reflect.DeepEqual(*vector, vectors[1]) {
+		t.Fatalf("expected: (b) (%v) | got: (%v) (%v)", vectors[1], value,
+			*vector)
+	}
+	if _, _, err := lshforest.Get(1); err != ErrNotFound {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrNotFound, err)
+	}
+
+	ids, err := lshforest.QueryIDs(&vectors[2], 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*ids)[0] != 5 || len(*ids) != 4 {
+		t.Fatalf("expected: ([5 ...]) | got: (%v)", *ids)
+	}
+	results, err := lshforest.QueryResults(&vectors[1], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*results)[0].ID != 10 || (*results)[0].Value != "b" {
+		t.Fatalf("expected: (10 b) | got: (%v)", (*results)[0])
+	}
+
+	if err := lshforest.Delete(5); err != nil {
+		t.Fatal(err)
+	}
+	if lshforest.Contains(5) || lshforest.Len() != 3 {
+		t.Fatalf("unexpected ids: (%v)", lshforest.IDs())
+	}
+	// a deleted id can be reused
+	if err := lshforest.InsertWithID(5, &vectors[2], "c"); err != nil {
+		t.Fatal(err)
+	}
+	ids, err = lshforest.QueryIDs(&vectors[2], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*ids)[0] != 5 {
+		t.Fatalf("expected: ([5]) | got: (%v)", *ids)
+	}
+}
+
+func TestTyped(t *testing.T) {
+	vectors := [][]float64{{1, 0, 0}, {1, 1, 0}, {0, 1, 0}, {0, 0, 1}}
+	values := []string{"a", "b", "c", "d"}
+	lshforest := NewTyped[string](3, 10, 3, Cosine, WithSeed(1))
+	if err := lshforest.InsertAll(&vectors, &values); err != nil {
+		t.Fatal(err)
+	}
+	got, err := lshforest.Query(&vectors[2], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value string = (*got)[0]
+	if value != "c" {
+		t.Fatalf("expected: (c) | got: (%v)", value)
+	}
+	results, err := lshforest.QueryResults(&vectors[3], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*results)[0].Value != "d" {
+		t.Fatalf("expected: (d) | got: (%v)", (*results)[0].Value)
+	}
+	if value, _, err := lshforest.Get(1); err != nil || value != "b" {
+		t.Fatalf("expected: (b) | got: (%v) (%v)", value, err)
+	}
+	if value, _, err := lshforest.Get(9); err != ErrNotFound || value != "" {
+		t.Fatalf("expected: () (%v) | got: (%v) (%v)", ErrNotFound, value, err)
+	}
+
+	ints := NewDefaultTyped[int](3, Cosine)
+	if err := ints.Insert(&vectors[0], 7); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ints.Query(&vectors[0], 1); err != nil || (*got)[0]+1 != 8 {
+		t.Fatalf("expected: (7) | got: (%v) (%v)", got, err)
+	}
+}
+
+func TestQueryContextPartialBudget(t *testing.T) {
+	const dim = 8
+	r := rand.New(rand.NewSource(7))
+	vectors, values := randomVectors(r, 2000, dim)
+	lshforest := New(4, 4, dim, Cosine, WithSeed(2))
+	if err := lshforest.InsertAll(&vectors, &values); err != nil {
+		t.Fatal(err)
+	}
+	// the first levels of each tree hold more candidates than are ranked
+	// once the context is done
+	var stats QueryStats
+	results, err := lshforest.QueryResultsContext(
+		&countdownContext{Context: context.Background(), n: 8}, &vectors[0],
+		2000, Partial(), Stats(&stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*results) != rankBatch || !stats.Partial ||
+		stats.Candidates != rankBatch {
+		t.Fatalf("expected: (%v) partial results | got: (%v) (%v)", rankBatch,
+			len(*results), stats)
+	}
+}
+
+func TestQueryContextPartialCollection(t *testing.T) {
+	const dim = 8
+	r := rand.New(rand.NewSource(7))
+	vectors, values := randomVectors(r, 2000, dim)
+	lshforest := New(1, 1, dim, Cosine, WithSeed(2))
+	if err := lshforest.InsertAll(&vectors, &values); err != nil {
+		t.Fatal(err)
+	}
+	// the subtree the query descends to holds about half of the elements,
+	// and its walk is cut off after a batch
+	var stats QueryStats
+	_, err := lshforest.QueryResultsContext(
+		&countdownContext{Context: context.Background(), n: 2}, &vectors[0],
+		2000, Partial(), Stats(&stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.Partial || stats.Collected >= 2*rankBatch {
+		t.Fatalf("expected: (< %v) collected | got: (%v)", 2*rankBatch, stats)
+	}
+}
+
+func TestInsertID(t *testing.T) {
+	vectors := [][]float64{{1, 0, 0}, {1, 1, 0}}
+	vectors32 := [][]float32{{0, 1, 0}, {0, 0, 1}}
+	sparseVector, _ := sparse.New([]uint{2}, []float64{1})
+	lshforest := New(3, 10, 3, Cosine, WithSeed(1))
+	exact := NewExact(3, Cosine)
+	if err := lshforest.InsertWithID(4, &vectors[0], "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := exact.InsertWithID(4, &vectors[0], "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	insertions := []struct {
+		name     string
+		forest   func() (uint, error)
+		exact    func() (uint, error)
+		expected uint
+	}{
+		{"InsertID", func() (uint, error) {
+			return lshforest.InsertID(&vectors[1], "b")
+		}, func() (uint, error) {
+			return exact.InsertID(&vectors[1], "b")
+		}, 5},
+		{"InsertAllID", func() (uint, error) {
+			return lshforest.InsertAllID(&vectors, &[]interface{}{"c", "d"})
+		}, func() (uint, error) {
+			return exact.InsertAllID(&vectors, &[]interface{}{"c", "d"})
+		}, 6},
+		{"InsertSparseID", func() (uint, error) {
+			return lshforest.InsertSparseID(sparseVector, "e")
+		}, func() (uint, error) {
+			return exact.InsertSparseID(sparseVector, "e")
+		}, 8},
+		{"InsertFloat32ID", func() (uint, error) {
+			return lshforest.InsertFloat32ID(&vectors32[0], "f")
+		}, func() (uint, error) {
+			return exact.InsertFloat32ID(&vectors32[0], "f")
+		}, 9},
+		{"InsertAllID of no vectors", func() (uint, error) {
+			return lshforest.InsertAllID(&[][]float64{}, &[]interface{}{})
+		}, func() (uint, error) {
+			return exact.InsertAllID(&[][]float64{}, &[]interface{}{})
+		}, 10},
+	}
+	for _, insertion := range insertions {
+		for _, insert := range []func() (uint, error){insertion.forest,
+			insertion.exact} {
+			if id, err := insert(); err != nil || id != insertion.expected {
+				t.Fatalf("%v expected: (%v) | got: (%v) (%v)", insertion.name,
+					insertion.expected, id, err)
+			}
+		}
+	}
+	first, err := lshforest.InsertAllFloat32ID(&vectors32,
+		&[]interface{}{"g", "h"})
+	if err != nil || first != 10 {
+		t.Fatalf("expected: (10) | got: (%v) (%v)", first, err)
+	}
+	if value, _, err := lshforest.Get(11); err != nil || value != "h" {
+		t.Fatalf("expected: (h) | got: (%v) (%v)", value, err)
+	}
+	if value, _, err := exact.Get(7); err != nil || value != "d" {
+		t.Fatalf("expected: (d) | got: (%v) (%v)", value, err)
+	}
+}
+
+func TestIDOverflow(t *testing.T) {
+	vector := []float64{1, 2, 3}
+	lshforest := NewDefault(3, Cosine)
+	exact := NewExact(3, Cosine)
+	for _, index := range []interface {
+		Insert(*[]float64, interface{}) error
+		InsertAll(*[][]float64, *[]interface{}) error
+		InsertWithID(uint, *[]float64, interface{}) error
+		Len() uint
+	}{lshforest, exact} {
+		if err := index.Insert(&vector, "a"); err != nil {
+			t.Fatal(err)
+		}
+		err := index.InsertWithID(math.MaxUint, &vector, "b")
+		if err != ErrIDOverflow {
+			t.Fatalf("expected: (%v) | got: (%v)", ErrIDOverflow, err)
+		}
+		// the largest id leaves no id for Insert and InsertAll
+		if err := index.InsertWithID(math.MaxUint-1, &vector, "b"); err != nil {
+			t.Fatal(err)
+		}
+		if err := index.Insert(&vector, "c"); err != ErrIDOverflow {
+			t.Fatalf("expected: (%v) | got: (%v)", ErrIDOverflow, err)
+		}
+		err = index.InsertAll(&[][]float64{vector}, &[]interface{}{"c"})
+		if err != ErrIDOverflow {
+			t.Fatalf("expected: (%v) | got: (%v)", ErrIDOverflow, err)
+		}
+		if index.Len() != 2 {
+			t.Fatalf("expected: (2) | got: (%v)", index.Len())
+		}
+	}
+	if _, err := lshforest.Query(&vector, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := new(LSHForest[interface{}]).ReadFrom(
+		bytes.NewReader(snapshot(t, lshforest))); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,217 @@
+package lshtree
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/justinfargnoli/lshforest/pkg/hash"
+	"io"
+)
+
+const (
+	hasLeft  = 1 << 0
+	hasRight = 1 << 1
+	hasRoot  = 1 << 2
+)
+
+// maxHashLen bounds the length of a decoded hash
+const maxHashLen = 1 << 16
+
+var errEncoding = errors.New("lshtree: invalid encoding")
+
+// Encode writes the structure of the trie to w. Each element is written as its
+// ID and hash, so its Vector and Value must be stored by the caller
+func (t *Trie[V]) Encode(w io.Writer) error {
+	if t.root == nil {
+		return writeUvarint(w, 0)
+	}
+	if err := writeUvarint(w, hasRoot); err != nil {
+		return err
+	}
+	return t.root.encode(w)
+}
+
+// Decode replaces the trie with the trie written by Encode. resolve returns the
+// element with the given ID and hash, whose ID and hash are set by Decode
+func (t *Trie[V]) Decode(r io.ByteReader, resolve func(id uint, hash hash.Signature) (Element[V], error)) error {
+	flags, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	t.root = nil
+	if flags&hasRoot == 0 {
+		return nil
+	}
+	root, err := decodeNode(r, nil, resolve)
+	if err != nil {
+		return err
+	}
+	t.root = root
+	return nil
+}
+
+func (n *Node[V]) encode(w io.Writer) error {
+	var flags uint64
+	if n.left != nil {
+		flags |= hasLeft
+	}
+	if n.right != nil {
+		flags |= hasRight
+	}
+	if err := writeUvarint(w, flags); err != nil {
+		return err
+	}
+	if err := writeUvarint(w, uint64(len(n.Elements))); err != nil {
+		return err
+	}
+	for _, element := range n.Elements {
+		if err := writeUvarint(w, uint64(element.ID)); err != nil {
+			return err
+		}
+		if err := writeHash(w, element.hash); err != nil {
+			return err
+		}
+	}
+	if n.left != nil {
+		if err := n.left.encode(w); err != nil {
+			return err
+		}
+	}
+	if n.right != nil {
+		return n.right.encode(w)
+	}
+	return nil
+}
+
+func decodeNode[V any](r io.ByteReader, parent *Node[V],
+	resolve func(id uint, hash hash.Signature) (Element[V], error)) (*Node[V], error) {
+	flags, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	node := &Node[V]{Parent: parent}
+	for i := uint64(0); i < count; i++ {
+		id, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := readHash(r)
+		if err != nil {
+			return nil, err
+		}
+		element, err := resolve(uint(id), hash)
+		if err != nil {
+			return nil, err
+		}
+		element.ID, element.hash = uint(id), hash
+		node.Elements = append(node.Elements, element)
+	}
+	if flags&hasLeft != 0 {
+		if node.left, err = decodeNode(r, node, resolve); err != nil {
+			return nil, err
+		}
+	}
+	if flags&hasRight != 0 {
+		if node.right, err = decodeNode(r, node, resolve); err != nil {
+			return nil, err
+		}
+	}
+	if !node.isInternal() && len(node.Elements) == 0 {
+		return nil, errEncoding
+	}
+	return node, nil
+}
+
+// writeHash writes the length of hash followed by its words in little endian
+// order
+func writeHash(w io.Writer, hash hash.Signature) error {
+	if err := writeUvarint(w, uint64(hash.Len())); err != nil {
+		return err
+	}
+	words := hash.Words()
+	packed := make([]byte, 8*len(words))
+	for i, word := range words {
+		binary.LittleEndian.PutUint64(packed[8*i:], word)
+	}
+	_, err := w.Write(packed)
+	return err
+}
+
+func readHash(r io.ByteReader) (hash.Signature, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return hash.Signature{}, err
+	}
+	if length > maxHashLen {
+		return hash.Signature{}, errEncoding
+	}
+	words := make([]uint64, (length+63)/64)
+	for i := range words {
+		for j := uint(0); j < 8; j++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return hash.Signature{}, err
+			}
+			words[i] |= uint64(b) << (8 * j)
+		}
+	}
+	return hash.FromWords(words, uint(length)), nil
+}
+
+func writeUvarint(w io.Writer, x uint64) error {
+	var b [binary.MaxVarintLen64]byte
+	_, err := w.Write(b[:binary.PutUvarint(b[:], x)])
+	return err
+}
+
+// Encode writes the elements of the array to w. Each element is written as its
+// ID and hash, so its Vector and Value must be stored by the caller
+func (s *Sorted[V]) Encode(w io.Writer) error {
+	if err := writeUvarint(w, uint64(len(s.elements))); err != nil {
+		return err
+	}
+	for _, element := range s.elements {
+		if err := writeUvarint(w, uint64(element.ID)); err != nil {
+			return err
+		}
+		if err := writeHash(w, element.hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode replaces the array with the array written by Encode. resolve returns
+// the element with the given ID and hash, whose ID and hash are set by Decode
+func (s *Sorted[V]) Decode(r io.ByteReader,
+	resolve func(id uint, hash hash.Signature) (Element[V], error)) error {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	var elements []Element[V]
+	for i := uint64(0); i < count; i++ {
+		id, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		hash, err := readHash(r)
+		if err != nil {
+			return err
+		}
+		element, err := resolve(uint(id), hash)
+		if err != nil {
+			return err
+		}
+		element.ID, element.hash = uint(id), hash
+		if len(elements) > 0 && !less(elements[len(elements)-1], element) {
+			return errEncoding
+		}
+		elements = append(elements, element)
+	}
+	s.elements = elements
+	return nil
+}
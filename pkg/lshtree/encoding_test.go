@@ -0,0 +1,64 @@
+package lshtree
+
+import (
+	"bytes"
+	"errors"
+	"github.com/justinfargnoli/lshforest/pkg/hash"
+	"testing"
+)
+
+func encodeDecode(t *testing.T, trie *Trie[interface{}], elements []Element[interface{}]) Trie[interface{}] {
+	var buf bytes.Buffer
+	if err := trie.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded := NewTrie[interface{}]()
+	err := decoded.Decode(&buf, func(id uint,
+		_ hash.Signature) (Element[interface{}], error) {
+		if id >= uint(len(elements)) {
+			return Element[interface{}]{}, errors.New("unknown id")
+		}
+		return Element[interface{}]{
+			Data: &Data[interface{}]{Value: elements[id].Value}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestEncode(t *testing.T) {
+	trie := NewTrie[interface{}]()
+	if decoded := encodeDecode(t, &trie, nil); decoded.root != nil {
+		t.Fatal("root != nil")
+	}
+
+	elements := withIDs(elements3Var)
+	insert(&trie, elements)
+	decoded := encodeDecode(t, &trie, elements)
+	if !EqArrString(valuesInorder(decoded), valuesInorder(trie)) {
+		t.Fatalf("expected: (%v) | got: (%v)", valuesInorder(trie),
+			valuesInorder(decoded))
+	}
+	testDescend(t, &decoded, hash.FromBits(1, 1, 1), "g", 2)
+	cursor, _ := decoded.Descend(hash.FromBits(0, 0, 0))
+	node := cursor.(*Node[interface{}])
+	if node.Parent == nil || node.Parent.Parent.Parent != decoded.root {
+		t.Fatal("parents weren't decoded")
+	}
+	for _, element := range elements {
+		if err := decoded.Delete(element); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	elements = withIDs(elements2Bucket)
+	trie = NewTrie[interface{}]()
+	insert(&trie, elements)
+	decoded = encodeDecode(t, &trie, elements)
+	bucket := decoded.Get(elements[0].hash)
+	if len(*bucket) != 2 || (*bucket)[0].ID != 0 || (*bucket)[1].ID != 1 ||
+		!(*bucket)[1].hash.Equal(elements[1].hash) {
+		t.Fatalf("got: (%v)", *bucket)
+	}
+}
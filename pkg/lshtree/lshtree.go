@@ -1,21 +1,76 @@
 package lshtree
 
-import "github.com/justinfargnoli/lshforest/pkg/hash"
+import (
+	"github.com/justinfargnoli/lshforest/pkg/hash"
+	"github.com/justinfargnoli/lshforest/pkg/sparse"
+)
 
-// Element is an element in the trie
-type Element struct {
-	hash   *[]hash.Bit
-	Vector *[]float64
-	Value  interface{}
+// Element is an element in the trie. ID identifies the element when it's
+// deleted. Its vector and value are in Data, which the elements of one vector
+// in every tree of a forest share, so each tree adds only a pointer
+type Element[V any] struct {
+	ID   uint
+	hash hash.Signature
+	*Data[V]
+}
+
+// Data is the vector and value of an element. Its vector is one of Vector,
+// Vector32 if it's float32, or Sparse if it's sparse
+type Data[V any] struct {
+	Vector   *[]float64
+	Vector32 *[]float32
+	Sparse   *sparse.Vector
+	Value    V
 }
 
 // NewElement constructs an element stored in the node of a LSHTree
-func NewElement(hash *[]hash.Bit, vector *[]float64, value interface{}) Element {
-	return Element{hash: hash, Vector: vector, Value: value}
+func NewElement[V any](id uint, hash hash.Signature, vector *[]float64,
+	value V) Element[V] {
+	return NewSharedElement(id, hash, &Data[V]{Vector: vector, Value: value})
+}
+
+// NewFloat32Element constructs an element, as NewElement does, whose vector
+// is float32
+func NewFloat32Element[V any](id uint, hash hash.Signature, vector *[]float32,
+	value V) Element[V] {
+	return NewSharedElement(id, hash, &Data[V]{Vector32: vector, Value: value})
+}
+
+// NewSparseElement constructs an element, as NewElement does, whose vector is
+// sparse
+func NewSparseElement[V any](id uint, hash hash.Signature,
+	vector *sparse.Vector, value V) Element[V] {
+	return NewSharedElement(id, hash, &Data[V]{Sparse: vector, Value: value})
+}
+
+// NewSharedElement constructs an element whose vector and value are data,
+// which may be shared with elements of other trees
+func NewSharedElement[V any](id uint, hash hash.Signature,
+	data *Data[V]) Element[V] {
+	return Element[V]{ID: id, hash: hash, Data: data}
+}
+
+// LSHTree is a trie within the LSHForest whose elements have values of type V
+type LSHTree[V any] interface {
+	Insert(Element[V]) error
+	Delete(Element[V]) error
+	Descend(hash.Signature) (Cursor[V], uint)
+	// Probe descends, as Descend does, with the given bit of hash flipped. It
+	// returns false if it can't reach a subtree below that bit, as the
+	// elements with the flipped bit are then under the subtree Descend reaches
+	Probe(hash hash.Signature, bit uint) (Cursor[V], uint, bool)
 }
 
-// LSHTree is a trie within the LSHForest
-type LSHTree interface {
-	Insert(Element) error
-	Descend(*[]hash.Bit) (*Node, uint)
+// Cursor is the subtree of an LSHTree which Descend reached, or one of its
+// ancestors
+type Cursor[V any] interface {
+	// Subtree appends the elements of the subtree to elements
+	Subtree(elements []Element[V]) []Element[V]
+	// Walk calls fn with each element of the subtree, in the order Subtree
+	// appends them, until fn returns false. It returns whether fn was called
+	// with every element
+	Walk(fn func(Element[V]) bool) bool
+	// Ascend returns the parent of the subtree, or false if the subtree is the
+	// whole LSHTree
+	Ascend() (Cursor[V], bool)
 }
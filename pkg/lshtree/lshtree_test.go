@@ -6,10 +6,15 @@ import (
 )
 
 func TestInterface(t *testing.T) {
-	var lshTree LSHTree
+	var lshTree LSHTree[interface{}]
 
-	trie := NewTrie()
+	trie := NewTrie[interface{}]()
 	lshTree = &trie
 
 	_ = fmt.Sprint(lshTree)
+
+	sorted := NewSorted[interface{}]()
+	lshTree = &sorted
+
+	_ = fmt.Sprint(lshTree)
 }
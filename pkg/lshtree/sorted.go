@@ -0,0 +1,186 @@
+package lshtree
+
+import (
+	"errors"
+	"github.com/justinfargnoli/lshforest/pkg/hash"
+	"math/bits"
+	"sort"
+)
+
+// Sorted is an LSHTree which keeps its elements in an array sorted by
+// Element.hash, in prefix order, and then by Element.ID. The elements sharing
+// a prefix are a range of the array, which is found by binary search. It's
+// more compact than a Trie, but Insert and Delete take linear time, so it
+// suits indexes which are built once with InsertAll
+type Sorted[V any] struct {
+	elements []Element[V]
+}
+
+// NewSorted constructs an empty Sorted
+func NewSorted[V any]() Sorted[V] {
+	return Sorted[V]{}
+}
+
+// Len returns the number of elements in the array
+func (s *Sorted[V]) Len() int {
+	return len(s.elements)
+}
+
+// Insert adds an element to the array
+func (s *Sorted[V]) Insert(element Element[V]) error {
+	if element.hash.Len() == 0 {
+		return errors.New("element.hash is empty")
+	}
+	i := sort.Search(len(s.elements), func(i int) bool {
+		return !less(s.elements[i], element)
+	})
+	s.elements = append(s.elements, Element[V]{})
+	copy(s.elements[i+1:], s.elements[i:])
+	s.elements[i] = element
+	return nil
+}
+
+// InsertAll adds the elements to the array in O((n + m) log(n + m)) time
+func (s *Sorted[V]) InsertAll(elements []Element[V]) error {
+	for _, element := range elements {
+		if element.hash.Len() == 0 {
+			return errors.New("element.hash is empty")
+		}
+	}
+	s.elements = append(s.elements, elements...)
+	sort.SliceStable(s.elements, func(i, j int) bool {
+		return less(s.elements[i], s.elements[j])
+	})
+	return nil
+}
+
+// Delete removes the element with element.ID and element.hash from the array
+func (s *Sorted[V]) Delete(element Element[V]) error {
+	if element.hash.Len() == 0 {
+		return errors.New("element.hash is empty")
+	}
+	i := sort.Search(len(s.elements), func(i int) bool {
+		return !less(s.elements[i], element)
+	})
+	if i == len(s.elements) || s.elements[i].ID != element.ID ||
+		!s.elements[i].hash.Equal(element.hash) {
+		return errors.New("element not found")
+	}
+	s.elements = append(s.elements[:i], s.elements[i+1:]...)
+	return nil
+}
+
+// Descend returns the range of elements with the longest prefix matching hash
+// and the length of that prefix
+func (s *Sorted[V]) Descend(hash hash.Signature) (Cursor[V], uint) {
+	if len(s.elements) == 0 {
+		return nil, 0
+	}
+	i := sort.Search(len(s.elements), func(i int) bool {
+		return compare(s.elements[i].hash, hash) >= 0
+	})
+	var depth uint
+	if i > 0 {
+		depth = s.elements[i-1].hash.CommonPrefix(hash)
+	}
+	if i < len(s.elements) {
+		if prefix := s.elements[i].hash.CommonPrefix(hash); prefix > depth {
+			depth = prefix
+		}
+	}
+	c := &sortedCursor[V]{elements: s.elements, hash: hash, index: i}
+	c.expand(depth)
+	return c, depth
+}
+
+// Probe returns the range of elements with the longest prefix matching hash
+// with bit flipped, and the length of that prefix, or false if the prefix
+// doesn't extend past bit
+func (s *Sorted[V]) Probe(hash hash.Signature, bit uint) (Cursor[V], uint,
+	bool) {
+	if bit >= hash.Len() {
+		return nil, 0, false
+	}
+	c, depth := s.Descend(hash.Flip(bit))
+	if c == nil || depth <= bit {
+		return nil, 0, false
+	}
+	return c, depth, true
+}
+
+// sortedCursor is the range [lo, hi) of elements which share a prefix of
+// length depth with hash. index is where hash would be inserted
+type sortedCursor[V any] struct {
+	elements []Element[V]
+	hash     hash.Signature
+	index    int
+	depth    uint
+	lo, hi   int
+}
+
+// expand sets the range to the elements which share a prefix of length depth
+// with hash. The length of the prefix shared with hash only grows towards
+// index, so the ends of the range are found by binary search
+func (c *sortedCursor[V]) expand(depth uint) {
+	c.depth = depth
+	c.lo = sort.Search(c.index, func(i int) bool {
+		return c.elements[i].hash.CommonPrefix(c.hash) >= depth
+	})
+	c.hi = c.index + sort.Search(len(c.elements)-c.index, func(i int) bool {
+		return c.elements[c.index+i].hash.CommonPrefix(c.hash) < depth
+	})
+}
+
+// Subtree appends the elements in the range to elements
+func (c *sortedCursor[V]) Subtree(elements []Element[V]) []Element[V] {
+	return append(elements, c.elements[c.lo:c.hi]...)
+}
+
+// Walk calls fn with each element in the range until fn returns false
+func (c *sortedCursor[V]) Walk(fn func(Element[V]) bool) bool {
+	for _, element := range c.elements[c.lo:c.hi] {
+		if !fn(element) {
+			return false
+		}
+	}
+	return true
+}
+
+// Ascend returns the range of elements which share a prefix one shorter with
+// hash, or false if the range is every element
+func (c *sortedCursor[V]) Ascend() (Cursor[V], bool) {
+	if c.depth == 0 {
+		return nil, false
+	}
+	parent := *c
+	parent.expand(c.depth - 1)
+	return &parent, true
+}
+
+func less[V any](e1, e2 Element[V]) bool {
+	if c := compare(e1.hash, e2.hash); c != 0 {
+		return c < 0
+	}
+	return e1.ID < e2.ID
+}
+
+// compare orders signatures by their bits from first to last, like strings.
+// It returns -1, 0 or 1
+func compare(s1, s2 hash.Signature) int {
+	w1, w2 := s1.Words(), s2.Words()
+	for i := 0; i < len(w1) && i < len(w2); i++ {
+		if diff := w1[i] ^ w2[i]; diff != 0 {
+			if w1[i]>>uint(bits.TrailingZeros64(diff))&1 == 0 {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case s1.Len() < s2.Len():
+		return -1
+	case s1.Len() > s2.Len():
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,224 @@
+package lshtree
+
+import (
+	"bytes"
+	"github.com/justinfargnoli/lshforest/pkg/hash"
+	"math/rand"
+	"testing"
+)
+
+func valuesSorted(sorted *Sorted[interface{}]) []string {
+	var values []string
+	for _, element := range sorted.elements {
+		values = append(values, element.Value.(string))
+	}
+	return values
+}
+
+func valuesCursor(cursor Cursor[interface{}]) []string {
+	var values []string
+	for _, element := range cursor.Subtree(nil) {
+		values = append(values, element.Value.(string))
+	}
+	return values
+}
+
+func TestSortedInsert(t *testing.T) {
+	elements := withIDs(elements3Var)
+	sorted := NewSorted[interface{}]()
+	for _, i := range []int{4, 0, 6, 2, 1, 5, 3} {
+		if err := sorted.Insert(elements[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{"a", "b", "c", "d", "e", "f", "g"}
+	if !EqArrString(valuesSorted(&sorted), expected) {
+		t.Fatalf("expected: (%v) | got: (%v)", expected, valuesSorted(&sorted))
+	}
+	if sorted.Insert(Element[interface{}]{}) == nil {
+		t.Fatal("inserted an element without a hash")
+	}
+
+	bulk := NewSorted[interface{}]()
+	if err := bulk.InsertAll(elements[4:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := bulk.InsertAll(elements[:4]); err != nil {
+		t.Fatal(err)
+	}
+	if !EqArrString(valuesSorted(&bulk), expected) {
+		t.Fatalf("expected: (%v) | got: (%v)", expected, valuesSorted(&bulk))
+	}
+}
+
+func TestSortedDescend(t *testing.T) {
+	sorted := NewSorted[interface{}]()
+	if cursor, depth := sorted.Descend(hash.FromBits(0, 0, 0)); cursor != nil ||
+		depth != 0 {
+		t.Fatalf("got: (%v, %v)", cursor, depth)
+	}
+	for _, element := range withIDs(elements3Var) {
+		sorted.Insert(element)
+	}
+
+	cursor, depth := sorted.Descend(hash.FromBits(1, 1, 1))
+	levels := [][]string{
+		{"g"},
+		{"e", "f", "g"},
+		{"a", "b", "c", "d", "e", "f", "g"},
+	}
+	for i, level := range levels {
+		if depth != uint(2-i) || !EqArrString(valuesCursor(cursor), level) {
+			t.Fatalf("expected: (%v, %v) | got: (%v, %v)", level, 2-i,
+				valuesCursor(cursor), depth)
+		}
+		var ok bool
+		if cursor, ok = cursor.Ascend(); ok != (i < 2) {
+			t.Fatalf("Ascend() at depth (%v) returned (%v)", depth, ok)
+		}
+		depth--
+	}
+
+	cursor, depth = sorted.Descend(hash.FromBits(0, 1, 0))
+	if depth != 3 || !EqArrString(valuesCursor(cursor), []string{"c"}) {
+		t.Fatalf("expected: ([c], 3) | got: (%v, %v)", valuesCursor(cursor),
+			depth)
+	}
+}
+
+func TestSortedProbe(t *testing.T) {
+	sorted := NewSorted[interface{}]()
+	if _, _, ok := sorted.Probe(hash.FromBits(0, 0, 0), 0); ok {
+		t.Fatal("probed an empty array")
+	}
+	for _, element := range withIDs(elements3Var) {
+		sorted.Insert(element)
+	}
+
+	cursor, depth, ok := sorted.Probe(hash.FromBits(1, 1, 1), 1)
+	if !ok || depth != 3 || !EqArrString(valuesCursor(cursor), []string{"f"}) {
+		t.Fatalf("expected: ([f], 3) | got: (%v, %v, %v)",
+			valuesCursor(cursor), depth, ok)
+	}
+	cursor, _ = cursor.Ascend()
+	if !EqArrString(valuesCursor(cursor), []string{"e", "f"}) {
+		t.Fatalf("expected: ([e f]) | got: (%v)", valuesCursor(cursor))
+	}
+
+	// no element has the prefix 0, 0, 1, 1
+	sorted.Delete(withIDs(elements3Var)[1])
+	if _, _, ok := sorted.Probe(hash.FromBits(0, 0, 0), 2); ok {
+		t.Fatal("probed the prefix 0, 0, 1")
+	}
+	if _, _, ok := sorted.Probe(hash.FromBits(1, 1, 1), 3); ok {
+		t.Fatal("probed past the end of the hash")
+	}
+}
+
+func TestSortedDelete(t *testing.T) {
+	elements := withIDs(elements2Bucket)
+	sorted := NewSorted[interface{}]()
+	for _, element := range elements {
+		sorted.Insert(element)
+	}
+	if sorted.Delete(Element[interface{}]{ID: 100, hash: elements[0].hash}) == nil {
+		t.Fatal("deleted an element which was never inserted")
+	}
+	if err := sorted.Delete(elements[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := sorted.Delete(elements[2]); err != nil {
+		t.Fatal(err)
+	}
+	if !EqArrString(valuesSorted(&sorted), []string{"a", "d"}) {
+		t.Fatal(valuesSorted(&sorted))
+	}
+	if sorted.Delete(elements[1]) == nil {
+		t.Fatal("deleted an element twice")
+	}
+}
+
+func TestSortedEncode(t *testing.T) {
+	elements := withIDs(elements3)
+	sorted := NewSorted[interface{}]()
+	sorted.InsertAll(elements)
+	var buf bytes.Buffer
+	if err := sorted.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded := NewSorted[interface{}]()
+	err := decoded.Decode(&buf, func(id uint,
+		_ hash.Signature) (Element[interface{}], error) {
+		return Element[interface{}]{
+			Data: &Data[interface{}]{Value: elements[id].Value}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !EqArrElement(&decoded.elements, &sorted.elements) {
+		t.Fatalf("expected: (%v) | got: (%v)", sorted.elements,
+			decoded.elements)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		s1, s2 hash.Signature
+		c      int
+	}{
+		{hash.FromBits(0, 1), hash.FromBits(1, 0), -1},
+		{hash.FromBits(1, 0, 1), hash.FromBits(1, 0, 0), 1},
+		{hash.FromBits(1, 1), hash.FromBits(1, 1), 0},
+		{hash.FromBits(1), hash.FromBits(1, 0), -1},
+	}
+	for _, test := range tests {
+		if c := compare(test.s1, test.s2); c != test.c {
+			t.Fatalf("compare(%v, %v) expected: (%v) | got: (%v)",
+				test.s1.Bits(), test.s2.Bits(), test.c, c)
+		}
+	}
+}
+
+func TestSortedDescendRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomHash := func() hash.Signature {
+		bits := make([]hash.Bit, 70)
+		for i := range bits {
+			bits[i] = hash.Bit(r.Intn(2))
+		}
+		return hash.FromBits(bits...)
+	}
+	sorted := NewSorted[interface{}]()
+	var elements []Element[interface{}]
+	for i := 0; i < 200; i++ {
+		elements = append(elements, Element[interface{}]{ID: uint(i), hash: randomHash()})
+		sorted.Insert(elements[i])
+	}
+
+	for q := 0; q < 50; q++ {
+		query := randomHash()
+		var longest uint
+		for _, element := range elements {
+			if p := element.hash.CommonPrefix(query); p > longest {
+				longest = p
+			}
+		}
+		cursor, depth := sorted.Descend(query)
+		if depth != longest {
+			t.Fatalf("expected depth: (%v) | got: (%v)", longest, depth)
+		}
+		for ok := true; ok; cursor, ok = cursor.Ascend() {
+			expected := 0
+			for _, element := range elements {
+				if element.hash.CommonPrefix(query) >= depth {
+					expected++
+				}
+			}
+			if got := len(cursor.Subtree(nil)); got != expected {
+				t.Fatalf("depth (%v) expected: (%v) | got: (%v)", depth,
+					expected, got)
+			}
+			depth--
+		}
+	}
+}
@@ -5,69 +5,139 @@ import (
 	"github.com/justinfargnoli/lshforest/pkg/hash"
 )
 
-// Trie is a prefix tree which uses a Element.hash, a []Bit, to determine the
-// elements prefix
-type Trie struct {
-	root *Node
+// Trie is a prefix tree which uses a Element.hash, a hash.Signature, to
+// determine the elements prefix
+type Trie[V any] struct {
+	root *Node[V]
 }
 
 // NewTrie constructs an empty Trie
-func NewTrie() Trie {
-	return Trie{}
+func NewTrie[V any]() Trie[V] {
+	return Trie[V]{}
 }
 
 // Preorder performs a preorder traversal of the tree
-func (t Trie) Preorder(function func(*Node)) {
+func (t Trie[V]) Preorder(function func(*Node[V])) {
 	if t.root != nil {
 		t.root.preorder(function)
 	}
 }
 
 // Postorder performs a postorder traversal of the tree
-func (t Trie) Postorder(function func(*Node)) {
+func (t Trie[V]) Postorder(function func(*Node[V])) {
 	if t.root != nil {
 		t.root.postorder(function)
 	}
 }
 
 // Inorder performs a inorder traversal of the tree
-func (t Trie) Inorder(function func(*Node)) {
+func (t Trie[V]) Inorder(function func(*Node[V])) {
 	if t.root != nil {
 		t.root.inorder(function)
 	}
 }
 
 // Insert adds an element to the tire
-func (t *Trie) Insert(element Element) error {
-	if element.hash == nil {
-		return errors.New("element.hash == nil")
+func (t *Trie[V]) Insert(element Element[V]) error {
+	if element.hash.Len() == 0 {
+		return errors.New("element.hash is empty")
 	}
 	if t.root == nil {
-		t.root = &Node{Elements: []Element{element}}
+		t.root = &Node[V]{Elements: []Element[V]{element}}
 	} else {
 		t.root.insert(element, 0)
 	}
 	return nil
 }
 
-// Descend returns the leaf with the larges prefix matching hash
-func (t *Trie) Descend(hash *[]hash.Bit) (*Node, uint) {
-	if hash == nil {
-		panic("lshforest/lshtree/Trie Trie.Descend()")
+// Delete removes the element with element.ID from the bucket at element.hash.
+// Internal nodes which are left with a single leaf below them are collapsed
+// into that leaf, so the trie is shaped as if element was never inserted
+func (t *Trie[V]) Delete(element Element[V]) error {
+	if element.hash.Len() == 0 {
+		return errors.New("element.hash is empty")
 	}
+	var node *Node[V]
+	if t.root != nil {
+		node = t.root.find(element.hash, 0)
+	}
+	if node == nil {
+		return errors.New("element not found")
+	}
+	for i := range node.Elements {
+		if node.Elements[i].ID == element.ID {
+			node.Elements = append(node.Elements[:i], node.Elements[i+1:]...)
+			t.collapse(node)
+			return nil
+		}
+	}
+	return errors.New("element not found")
+}
+
+// collapse restores the shape of the trie from node up to the root after an
+// element is removed from node
+func (t *Trie[V]) collapse(node *Node[V]) {
+	for node != nil {
+		parent := node.Parent
+		if node.isInternal() {
+			child := node.onlyChild()
+			if child == nil || !child.isLeaf() {
+				return
+			}
+			node.Elements = child.Elements
+			node.left, node.right = nil, nil
+		} else if len(node.Elements) == 0 {
+			if parent == nil {
+				t.root = nil
+			} else if parent.left == node {
+				parent.left = nil
+			} else {
+				parent.right = nil
+			}
+		} else if !node.isLeaf() {
+			return
+		}
+		node = parent
+	}
+}
+
+// Descend returns the leaf with the larges prefix matching hash and its depth.
+// The leaf is a *Node
+func (t *Trie[V]) Descend(hash hash.Signature) (Cursor[V], uint) {
 	if t.root == nil {
 		return nil, 0
 	}
 	return t.root.descend(hash, 0)
 }
 
-// Get returns elements with equal hash values
-func (t *Trie) Get(hash *[]hash.Bit) *[]Element {
-	if hash == nil {
-		panic("lshforest/lshtree/Trie Trie.Get()")
+// Probe returns the leaf with the largest prefix matching hash with bit
+// flipped, and its depth, or false if no node has the first bit + 1 bits of
+// that hash as its path
+func (t *Trie[V]) Probe(hash hash.Signature, bit uint) (Cursor[V], uint,
+	bool) {
+	if t.root == nil || bit >= hash.Len() {
+		return nil, 0, false
 	}
+	probe := hash.Flip(bit)
+	node := t.root
+	for depth := uint(0); depth <= bit; depth++ {
+		child := node.left
+		if probe.Bit(depth) == right {
+			child = node.right
+		}
+		if child == nil {
+			return nil, 0, false
+		}
+		node = child
+	}
+	leaf, depth := node.descend(probe, bit+1)
+	return leaf, depth, true
+}
+
+// Get returns elements with equal hash values
+func (t *Trie[V]) Get(hash hash.Signature) *[]Element[V] {
 	if t.root == nil {
-		return &[]Element{}
+		return &[]Element[V]{}
 	}
 	return t.root.get(hash, 0)
 }
@@ -78,40 +148,78 @@ const (
 )
 
 // Node is a node in the Trie
-type Node struct {
-	Elements            []Element
-	left, right, Parent *Node
+type Node[V any] struct {
+	Elements            []Element[V]
+	left, right, Parent *Node[V]
 }
 
-func (n *Node) isInternal() bool {
+func (n *Node[V]) isInternal() bool {
 	return n.left != nil || n.right != nil
 }
 
-func (n *Node) isLeaf() bool {
+func (n *Node[V]) isLeaf() bool {
 	return n.left == nil && n.right == nil && len(n.Elements) == 1
 }
 
-func (n *Node) isLeafBucket() bool {
+func (n *Node[V]) onlyChild() *Node[V] {
+	if n.left == nil {
+		return n.right
+	}
+	if n.right == nil {
+		return n.left
+	}
+	return nil
+}
+
+func (n *Node[V]) isLeafBucket() bool {
 	return n.left == nil && n.right == nil && len(n.Elements) >= 1
 }
 
 // Decendants returns the children of the node
-func (n *Node) Decendants() []*Node {
-	var nodes []*Node
+func (n *Node[V]) Decendants() []*Node[V] {
+	var nodes []*Node[V]
 	if n.left != nil {
-		n.left.preorder(func(node *Node) {
+		n.left.preorder(func(node *Node[V]) {
 			nodes = append(nodes, node)
 		})
 	}
 	if n.right != nil {
-		n.right.preorder(func(node *Node) {
+		n.right.preorder(func(node *Node[V]) {
 			nodes = append(nodes, node)
 		})
 	}
 	return nodes
 }
 
-func (n *Node) preorder(function func(*Node)) {
+// Subtree appends the elements of the node and its descendants to elements
+func (n *Node[V]) Subtree(elements []Element[V]) []Element[V] {
+	n.preorder(func(node *Node[V]) {
+		elements = append(elements, node.Elements...)
+	})
+	return elements
+}
+
+// Walk calls fn with each element of the node and its descendants until fn
+// returns false
+func (n *Node[V]) Walk(fn func(Element[V]) bool) bool {
+	for _, element := range n.Elements {
+		if !fn(element) {
+			return false
+		}
+	}
+	return (n.left == nil || n.left.Walk(fn)) &&
+		(n.right == nil || n.right.Walk(fn))
+}
+
+// Ascend returns the parent of the node, or false if the node is the root
+func (n *Node[V]) Ascend() (Cursor[V], bool) {
+	if n.Parent == nil {
+		return nil, false
+	}
+	return n.Parent, true
+}
+
+func (n *Node[V]) preorder(function func(*Node[V])) {
 	function(n)
 	if n.left != nil {
 		n.left.preorder(function)
@@ -121,7 +229,7 @@ func (n *Node) preorder(function func(*Node)) {
 	}
 }
 
-func (n *Node) postorder(function func(*Node)) {
+func (n *Node[V]) postorder(function func(*Node[V])) {
 	if n.left != nil {
 		n.left.postorder(function)
 	}
@@ -131,7 +239,7 @@ func (n *Node) postorder(function func(*Node)) {
 	function(n)
 }
 
-func (n *Node) inorder(function func(*Node)) {
+func (n *Node[V]) inorder(function func(*Node[V])) {
 	if n.left != nil {
 		n.left.inorder(function)
 	}
@@ -141,9 +249,9 @@ func (n *Node) inorder(function func(*Node)) {
 	}
 }
 
-func (n *Node) descend(hash *[]hash.Bit, depth uint) (*Node, uint) {
+func (n *Node[V]) descend(hash hash.Signature, depth uint) (*Node[V], uint) {
 	if n.isInternal() {
-		if (*hash)[depth] == left {
+		if hash.Bit(depth) == left {
 			if n.left == nil {
 				return n.right.descend(hash, depth+1)
 			}
@@ -157,61 +265,68 @@ func (n *Node) descend(hash *[]hash.Bit, depth uint) (*Node, uint) {
 	return n, depth
 }
 
-func (n *Node) get(hash *[]hash.Bit, depth uint) *[]Element {
+func (n *Node[V]) get(hash hash.Signature, depth uint) *[]Element[V] {
+	if node := n.find(hash, depth); node != nil {
+		return &node.Elements
+	}
+	return &[]Element[V]{}
+}
+
+func (n *Node[V]) find(hash hash.Signature, depth uint) *Node[V] {
 	if n.isInternal() {
-		if (*hash)[depth] == left {
+		if hash.Bit(depth) == left {
 			if n.left == nil {
-				return &[]Element{}
+				return nil
 			}
-			return n.left.get(hash, depth+1)
+			return n.left.find(hash, depth+1)
 		}
 		if n.right == nil {
-			return &[]Element{}
+			return nil
 		}
-		return n.right.get(hash, depth+1)
+		return n.right.find(hash, depth+1)
 	}
-	return &n.Elements
+	return n
 }
 
-func (n *Node) insert(element Element, depth uint) {
+func (n *Node[V]) insert(element Element[V], depth uint) {
 	if n.isInternal() {
-		if (*element.hash)[depth] == left {
+		if element.hash.Bit(depth) == left {
 			if n.left == nil {
-				n.left = &Node{Elements: []Element{element}, Parent: n}
+				n.left = &Node[V]{Elements: []Element[V]{element}, Parent: n}
 			} else {
 				n.left.insert(element, depth+1)
 			}
 		} else {
 			if n.right == nil {
-				n.right = &Node{Elements: []Element{element}, Parent: n}
+				n.right = &Node[V]{Elements: []Element[V]{element}, Parent: n}
 			} else {
 				n.right.insert(element, depth+1)
 			}
 		}
 	} else if n.isLeaf() {
-		if depth == uint(len(*element.hash)) {
+		if depth == element.hash.Len() {
 			n.Elements = append(n.Elements, element)
 			return
 		}
-		if (*element.hash)[depth] == (*n.Elements[0].hash)[depth] { // they're going the same way
-			if (*element.hash)[depth] == left { // they're going left
-				n.left = &Node{Parent: n, Elements: n.Elements}
-				n.Elements = []Element{}
+		if element.hash.Bit(depth) == n.Elements[0].hash.Bit(depth) { // they're going the same way
+			if element.hash.Bit(depth) == left { // they're going left
+				n.left = &Node[V]{Parent: n, Elements: n.Elements}
+				n.Elements = []Element[V]{}
 				n.left.insert(element, depth+1)
 			} else { // they're going right
-				n.right = &Node{Parent: n, Elements: n.Elements}
-				n.Elements = []Element{}
+				n.right = &Node[V]{Parent: n, Elements: n.Elements}
+				n.Elements = []Element[V]{}
 				n.right.insert(element, depth+1)
 			}
 		} else { // they're going different ways
-			if (*element.hash)[depth] == left { // element goes left & node goes right
-				n.left = &Node{Parent: n, Elements: []Element{element}}
-				n.right = &Node{Parent: n, Elements: n.Elements}
-				n.Elements = []Element{}
+			if element.hash.Bit(depth) == left { // element goes left & node goes right
+				n.left = &Node[V]{Parent: n, Elements: []Element[V]{element}}
+				n.right = &Node[V]{Parent: n, Elements: n.Elements}
+				n.Elements = []Element[V]{}
 			} else { // node goes left & element goes right
-				n.left = &Node{Parent: n, Elements: n.Elements}
-				n.right = &Node{Parent: n, Elements: []Element{element}}
-				n.Elements = []Element{}
+				n.left = &Node[V]{Parent: n, Elements: n.Elements}
+				n.right = &Node[V]{Parent: n, Elements: []Element[V]{element}}
+				n.Elements = []Element[V]{}
 			}
 		}
 	} else {
@@ -2,59 +2,61 @@ package lshtree
 
 import "github.com/justinfargnoli/lshforest/pkg/hash"
 
+import "reflect"
+
 import "testing"
 
 var (
-	elements1 = []Element{
-		{hash: &[]hash.Bit{0}, Value: "a"},
-		{hash: &[]hash.Bit{1}, Value: "b"},
+	elements1 = []Element[interface{}]{
+		{hash: hash.FromBits(0), Data: &Data[interface{}]{Value: "a"}},
+		{hash: hash.FromBits(1), Data: &Data[interface{}]{Value: "b"}},
 	}
 
-	elements2 = []Element{
-		{hash: &[]hash.Bit{0, 0}, Value: "a"},
-		{hash: &[]hash.Bit{0, 1}, Value: "b"},
-		{hash: &[]hash.Bit{1, 0}, Value: "c"},
-		{hash: &[]hash.Bit{1, 1}, Value: "d"},
+	elements2 = []Element[interface{}]{
+		{hash: hash.FromBits(0, 0), Data: &Data[interface{}]{Value: "a"}},
+		{hash: hash.FromBits(0, 1), Data: &Data[interface{}]{Value: "b"}},
+		{hash: hash.FromBits(1, 0), Data: &Data[interface{}]{Value: "c"}},
+		{hash: hash.FromBits(1, 1), Data: &Data[interface{}]{Value: "d"}},
 	}
 
-	elements2Bucket = []Element{
-		{hash: &[]hash.Bit{0, 0}, Value: "a"},
-		{hash: &[]hash.Bit{0, 0}, Value: "b"},
-		{hash: &[]hash.Bit{1, 1}, Value: "c"},
-		{hash: &[]hash.Bit{1, 1}, Value: "d"},
+	elements2Bucket = []Element[interface{}]{
+		{hash: hash.FromBits(0, 0), Data: &Data[interface{}]{Value: "a"}},
+		{hash: hash.FromBits(0, 0), Data: &Data[interface{}]{Value: "b"}},
+		{hash: hash.FromBits(1, 1), Data: &Data[interface{}]{Value: "c"}},
+		{hash: hash.FromBits(1, 1), Data: &Data[interface{}]{Value: "d"}},
 	}
 
-	elements3 = []Element{
-		{hash: &[]hash.Bit{0, 0, 0}, Value: "a"},
-		{hash: &[]hash.Bit{0, 0, 1}, Value: "b"},
-		{hash: &[]hash.Bit{0, 1, 0}, Value: "c"},
-		{hash: &[]hash.Bit{0, 1, 1}, Value: "d"},
-		{hash: &[]hash.Bit{1, 0, 0}, Value: "e"},
-		{hash: &[]hash.Bit{1, 0, 1}, Value: "f"},
-		{hash: &[]hash.Bit{1, 1, 0}, Value: "g"},
-		{hash: &[]hash.Bit{1, 1, 1}, Value: "h"},
+	elements3 = []Element[interface{}]{
+		{hash: hash.FromBits(0, 0, 0), Data: &Data[interface{}]{Value: "a"}},
+		{hash: hash.FromBits(0, 0, 1), Data: &Data[interface{}]{Value: "b"}},
+		{hash: hash.FromBits(0, 1, 0), Data: &Data[interface{}]{Value: "c"}},
+		{hash: hash.FromBits(0, 1, 1), Data: &Data[interface{}]{Value: "d"}},
+		{hash: hash.FromBits(1, 0, 0), Data: &Data[interface{}]{Value: "e"}},
+		{hash: hash.FromBits(1, 0, 1), Data: &Data[interface{}]{Value: "f"}},
+		{hash: hash.FromBits(1, 1, 0), Data: &Data[interface{}]{Value: "g"}},
+		{hash: hash.FromBits(1, 1, 1), Data: &Data[interface{}]{Value: "h"}},
 	}
 
-	elements3Var = []Element{
-		{hash: &[]hash.Bit{0, 0, 0}, Value: "a"},
-		{hash: &[]hash.Bit{0, 0, 1}, Value: "b"},
-		{hash: &[]hash.Bit{0, 1, 0}, Value: "c"},
-		{hash: &[]hash.Bit{0, 1, 1}, Value: "d"},
-		{hash: &[]hash.Bit{1, 0, 0}, Value: "e"},
-		{hash: &[]hash.Bit{1, 0, 1}, Value: "f"},
-		{hash: &[]hash.Bit{1, 1, 0}, Value: "g"},
+	elements3Var = []Element[interface{}]{
+		{hash: hash.FromBits(0, 0, 0), Data: &Data[interface{}]{Value: "a"}},
+		{hash: hash.FromBits(0, 0, 1), Data: &Data[interface{}]{Value: "b"}},
+		{hash: hash.FromBits(0, 1, 0), Data: &Data[interface{}]{Value: "c"}},
+		{hash: hash.FromBits(0, 1, 1), Data: &Data[interface{}]{Value: "d"}},
+		{hash: hash.FromBits(1, 0, 0), Data: &Data[interface{}]{Value: "e"}},
+		{hash: hash.FromBits(1, 0, 1), Data: &Data[interface{}]{Value: "f"}},
+		{hash: hash.FromBits(1, 1, 0), Data: &Data[interface{}]{Value: "g"}},
 	}
 )
 
-func insert(trie *Trie, elements []Element) {
+func insert(trie *Trie[interface{}], elements []Element[interface{}]) {
 	for _, element := range elements {
 		trie.Insert(element)
 	}
 }
 
-func valuesInorder(trie Trie) []string {
+func valuesInorder(trie Trie[interface{}]) []string {
 	var values []string
-	trie.Inorder(func(node *Node) {
+	trie.Inorder(func(node *Node[interface{}]) {
 		for _, element := range node.Elements {
 			values = append(values, element.Value.(string))
 		}
@@ -74,12 +76,14 @@ func EqArrString(s1, s2 []string) bool {
 	return true
 }
 
-func EqArrElement(e1, e2 *[]Element) bool {
+func EqArrElement(e1, e2 *[]Element[interface{}]) bool {
 	if len(*e1) != len(*e2) {
 		return false
 	}
 	for i := range *e1 {
-		if (*e1)[i] != (*e2)[i] {
+		if (*e1)[i].ID != (*e2)[i].ID || (*e1)[i].Value != (*e2)[i].Value ||
+			(*e1)[i].Vector != (*e2)[i].Vector ||
+			!(*e1)[i].hash.Equal((*e2)[i].hash) {
 			return false
 		}
 	}
@@ -87,38 +91,38 @@ func EqArrElement(e1, e2 *[]Element) bool {
 }
 
 func TestEmptyTrie(t *testing.T) {
-	trie := NewTrie()
-	trie.Preorder(func(node *Node) {})
-	trie.Postorder(func(node *Node) {})
-	trie.Inorder(func(node *Node) {})
-	trie.Get(&[]hash.Bit{})
-	trie.Descend(&[]hash.Bit{})
-	trie.Insert(Element{})
+	trie := NewTrie[interface{}]()
+	trie.Preorder(func(node *Node[interface{}]) {})
+	trie.Postorder(func(node *Node[interface{}]) {})
+	trie.Inorder(func(node *Node[interface{}]) {})
+	trie.Get(hash.FromBits())
+	trie.Descend(hash.FromBits())
+	trie.Insert(Element[interface{}]{})
 	if trie.root != nil {
 		t.Fatal("root != nil")
 	}
 }
 
 func TestInsert(t *testing.T) {
-	trie := NewTrie()
+	trie := NewTrie[interface{}]()
 	insert(&trie, elements1)
 	if !EqArrString(valuesInorder(trie), []string{"a", "b"}) {
 		t.FailNow()
 	}
 
-	trie = NewTrie()
+	trie = NewTrie[interface{}]()
 	insert(&trie, elements2)
 	if !EqArrString(valuesInorder(trie), []string{"a", "b", "c", "d"}) {
 		t.FailNow()
 	}
 
-	trie = NewTrie()
+	trie = NewTrie[interface{}]()
 	insert(&trie, elements2Bucket)
 	if !EqArrString(valuesInorder(trie), []string{"a", "b", "c", "d"}) {
 		t.FailNow()
 	}
 
-	trie = NewTrie()
+	trie = NewTrie[interface{}]()
 	insert(&trie, elements3)
 	if !EqArrString(valuesInorder(trie), []string{"a", "b", "c", "d", "e", "f", "g", "h"}) {
 		t.FailNow()
@@ -126,52 +130,188 @@ func TestInsert(t *testing.T) {
 }
 
 func TestDescend(t *testing.T) {
-	trie := NewTrie()
+	trie := NewTrie[interface{}]()
 	insert(&trie, elements3Var)
 
-	node, depth := trie.Descend(&[]hash.Bit{0, 0, 0})
+	cursor, depth := trie.Descend(hash.FromBits(0, 0, 0))
+	node := cursor.(*Node[interface{}])
 	if (*node).Elements[0].Value != "a" || depth != 3 {
 		t.Fatalf("expected: (a, 3) | got: (%v, %v)\n",
 			(*node).Elements[0].Value, depth)
 	}
 
-	node, depth = trie.Descend(&[]hash.Bit{0, 0, 1})
+	cursor, depth = trie.Descend(hash.FromBits(0, 0, 1))
+	node = cursor.(*Node[interface{}])
 	if (*node).Elements[0].Value != "b" || depth != 3 {
 		t.Fatalf("expected: (b, 3) | got: (%v, %v)\n",
 			(*node).Elements[0].Value, depth)
 	}
 
-	node, depth = trie.Descend(&[]hash.Bit{1, 1, 1})
+	cursor, depth = trie.Descend(hash.FromBits(1, 1, 1))
+	node = cursor.(*Node[interface{}])
 	if (*node).Elements[0].Value != "g" || depth != 2 {
 		t.Fatalf("expected: (g, 2) | got: (%v, %v)\n",
 			(*node).Elements[0].Value, depth)
 	}
 }
 
-func testGet(t *testing.T, e1, e2 *[]Element) {
+func TestProbe(t *testing.T) {
+	trie := NewTrie[interface{}]()
+	if _, _, ok := trie.Probe(hash.FromBits(0, 0, 0), 0); ok {
+		t.Fatal("probed an empty trie")
+	}
+	insert(&trie, elements3Var)
+
+	cursor, depth, ok := trie.Probe(hash.FromBits(1, 1, 1), 1)
+	if !ok || depth != 3 ||
+		cursor.(*Node[interface{}]).Elements[0].Value != "f" {
+		t.Fatalf("expected: (f, 3) | got: (%v, %v, %v)", cursor, depth, ok)
+	}
+	cursor, _ = cursor.Ascend()
+	if values := cursor.Subtree(nil); len(values) != 2 {
+		t.Fatalf("expected: ([e f]) | got: (%v)", values)
+	}
+
+	cursor, depth, ok = trie.Probe(hash.FromBits(0, 0, 0), 0)
+	if !ok || depth != 3 ||
+		cursor.(*Node[interface{}]).Elements[0].Value != "e" {
+		t.Fatalf("expected: (e, 3) | got: (%v, %v, %v)", cursor, depth, ok)
+	}
+
+	// g is the only element with the prefix 11, so its leaf is at depth 2
+	if _, _, ok := trie.Probe(hash.FromBits(1, 1, 1), 2); ok {
+		t.Fatal("probed below the leaf of g")
+	}
+	if _, _, ok := trie.Probe(hash.FromBits(1, 1, 1), 3); ok {
+		t.Fatal("probed past the end of the hash")
+	}
+}
+
+func testGet(t *testing.T, e1, e2 *[]Element[interface{}]) {
 	if !EqArrElement(e1, e2) {
 		t.Fatalf("get: (%v) | correct: (%v)\n", e1, e2)
 	}
 }
 
+func TestWalk(t *testing.T) {
+	trie := NewTrie[interface{}]()
+	insert(&trie, elements3Var)
+	sorted := NewSorted[interface{}]()
+	for _, element := range elements3Var {
+		sorted.Insert(element)
+	}
+	for _, tree := range []LSHTree[interface{}]{&trie, &sorted} {
+		cursor, _ := tree.Descend(hash.FromBits(0, 0, 0))
+		for parent, ok := cursor.Ascend(); ok; parent, ok = cursor.Ascend() {
+			cursor = parent
+		}
+		subtree := cursor.Subtree(nil)
+		var walked []Element[interface{}]
+		if !cursor.Walk(func(element Element[interface{}]) bool {
+			walked = append(walked, element)
+			return true
+		}) || !reflect.DeepEqual(walked, subtree) {
+			t.Fatalf("expected: (%v) | got: (%v)", subtree, walked)
+		}
+		walked = walked[:0]
+		if cursor.Walk(func(element Element[interface{}]) bool {
+			walked = append(walked, element)
+			return len(walked) < 2
+		}) || len(walked) != 2 {
+			t.Fatalf("expected: (2) elements | got: (%v)", walked)
+		}
+	}
+}
+
 func TestGet(t *testing.T) {
-	trie := NewTrie()
+	trie := NewTrie[interface{}]()
 	insert(&trie, elements1)
-	testGet(t, trie.Get(elements1[0].hash), &[]Element{elements1[0]})
-	testGet(t, trie.Get(elements1[1].hash), &[]Element{elements1[1]})
+	testGet(t, trie.Get(elements1[0].hash), &[]Element[interface{}]{elements1[0]})
+	testGet(t, trie.Get(elements1[1].hash), &[]Element[interface{}]{elements1[1]})
 
-	trie = NewTrie()
+	trie = NewTrie[interface{}]()
 	insert(&trie, elements2)
-	testGet(t, trie.Get(elements2[0].hash), &[]Element{elements2[0]})
-	testGet(t, trie.Get(elements2[1].hash), &[]Element{elements2[1]})
-	testGet(t, trie.Get(elements2[2].hash), &[]Element{elements2[2]})
-	testGet(t, trie.Get(elements2[3].hash), &[]Element{elements2[3]})
+	testGet(t, trie.Get(elements2[0].hash), &[]Element[interface{}]{elements2[0]})
+	testGet(t, trie.Get(elements2[1].hash), &[]Element[interface{}]{elements2[1]})
+	testGet(t, trie.Get(elements2[2].hash), &[]Element[interface{}]{elements2[2]})
+	testGet(t, trie.Get(elements2[3].hash), &[]Element[interface{}]{elements2[3]})
 
-	trie = NewTrie()
+	trie = NewTrie[interface{}]()
 	insert(&trie, elements2Bucket)
 	testGet(t, trie.Get(elements2Bucket[0].hash),
-		&[]Element{elements2Bucket[0], elements2Bucket[1]})
+		&[]Element[interface{}]{elements2Bucket[0], elements2Bucket[1]})
 	testGet(t, trie.Get(elements2Bucket[2].hash),
-		&[]Element{elements2Bucket[2], elements2Bucket[3]})
-	testGet(t, trie.Get(&[]hash.Bit{0, 1}), &[]Element{})
+		&[]Element[interface{}]{elements2Bucket[2], elements2Bucket[3]})
+	testGet(t, trie.Get(hash.FromBits(0, 1)), &[]Element[interface{}]{})
+}
+
+func withIDs(elements []Element[interface{}]) []Element[interface{}] {
+	var identified []Element[interface{}]
+	for i, element := range elements {
+		element.ID = uint(i)
+		identified = append(identified, element)
+	}
+	return identified
+}
+
+func testDescend(t *testing.T, trie *Trie[interface{}], hash hash.Signature, value string,
+	depth uint) {
+	cursor, d := trie.Descend(hash)
+	node := cursor.(*Node[interface{}])
+	if node.Elements[0].Value != value || d != depth {
+		t.Fatalf("expected: (%v, %v) | got: (%v, %v)\n", value, depth,
+			node.Elements[0].Value, d)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	elements := withIDs(elements3Var)
+	trie := NewTrie[interface{}]()
+	insert(&trie, elements)
+
+	if trie.Delete(Element[interface{}]{ID: 100, hash: elements[0].hash}) == nil {
+		t.Fatal("deleted an element which was never inserted")
+	}
+
+	if err := trie.Delete(elements[6]); err != nil { // g
+		t.Fatal(err)
+	}
+	if !EqArrString(valuesInorder(trie), []string{"a", "b", "c", "d", "e", "f"}) {
+		t.Fatal(valuesInorder(trie))
+	}
+	testDescend(t, &trie, hash.FromBits(1, 1, 1), "f", 3)
+
+	if err := trie.Delete(elements[5]); err != nil { // f
+		t.Fatal(err)
+	}
+	testDescend(t, &trie, hash.FromBits(1, 1, 1), "e", 1)
+	testDescend(t, &trie, hash.FromBits(0, 1, 1), "d", 3)
+
+	for _, element := range elements[:5] {
+		if err := trie.Delete(element); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if trie.root != nil {
+		t.Fatal("root != nil")
+	}
+	if trie.Delete(elements[0]) == nil {
+		t.Fatal("deleted from an empty trie")
+	}
+}
+
+func TestDeleteBucket(t *testing.T) {
+	elements := withIDs(elements2Bucket)
+	trie := NewTrie[interface{}]()
+	insert(&trie, elements)
+
+	if err := trie.Delete(elements[0]); err != nil { // a
+		t.Fatal(err)
+	}
+	testGet(t, trie.Get(elements[1].hash), &[]Element[interface{}]{elements[1]})
+	testDescend(t, &trie, hash.FromBits(0, 0), "b", 1)
+
+	insert(&trie, elements[:1])
+	testGet(t, trie.Get(elements[0].hash), &[]Element[interface{}]{elements[1], elements[0]})
+	testDescend(t, &trie, hash.FromBits(0, 0), "b", 2)
 }
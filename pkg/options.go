@@ -0,0 +1,236 @@
+package lshforest
+
+import (
+	"math"
+	"math/rand"
+	"runtime"
+)
+
+// Option configures an LSHForest constructed by New
+type Option func(*config)
+
+type config struct {
+	source rand.Source
+	seeds  *[]int64
+	codec  ValueCodec
+
+	concurrent bool
+	workers    int
+	width      float64
+	backend    uint
+	multiplier uint
+	probes     uint
+	zeroCopy   bool
+}
+
+// WithSeed derives the seed of each tree's hasher from seed, so LSHForests
+// constructed with the same seed and parameters hash identically
+func WithSeed(seed int64) Option {
+	return WithSource(rand.NewSource(seed))
+}
+
+// WithSource derives the seed of each tree's hasher from source
+func WithSource(source rand.Source) Option {
+	return func(c *config) {
+		c.source = source
+	}
+}
+
+// WithTreeSeeds seeds the hasher of tree i with seeds[i]. It rebuilds the
+// hashers of an LSHForest from the seeds returned by its Seeds method. It
+// takes precedence over WithSeed and WithSource
+func WithTreeSeeds(seeds []int64) Option {
+	return func(c *config) {
+		c.seeds = &seeds
+	}
+}
+
+// WithValueCodec encodes and decodes values with codec in WriteTo and
+// ReadFrom. By default, values are encoded with encoding/gob as their type V
+func WithValueCodec(codec ValueCodec) Option {
+	return func(c *config) {
+		c.codec = codec
+	}
+}
+
+// WithConcurrency makes the LSHForest safe for concurrent use. Queries run in
+// parallel with each other while each tree is locked for one Insert, Delete or
+// Update at a time. ReadFrom must still not be called concurrently
+func WithConcurrency() Option {
+	return func(c *config) {
+		c.concurrent = true
+	}
+}
+
+// WithWorkers hashes, inserts into and descends the trees on up to workers
+// goroutines, and InsertAll hashes batches of vectors on up to workers
+// goroutines. 0 workers means runtime.GOMAXPROCS(0) workers. By default the
+// trees are worked on one after another
+func WithWorkers(workers uint) Option {
+	return func(c *config) {
+		c.workers = int(workers)
+		if workers == 0 {
+			c.workers = runtime.GOMAXPROCS(0)
+		}
+	}
+}
+
+// WithBucketWidth sets the bucket width of the p-stable hashers of a Euclidean
+// LSHForest. It should be on the order of the distance between near
+// neighbors. It's 4 by default, and must be positive and finite
+func WithBucketWidth(width float64) Option {
+	if !(width > 0) || math.IsInf(width, 1) {
+		panic("lshforest WithBucketWidth() needs a positive width")
+	}
+	return func(c *config) {
+		c.width = width
+	}
+}
+
+// WithBackend sets how each tree is stored: TrieBackend, the default, or
+// SortedBackend
+func WithBackend(backend uint) Option {
+	return func(c *config) {
+		c.backend = backend
+	}
+}
+
+// WithZeroCopy makes the LSHForest store the vectors passed to it instead of
+// copies of them. It saves a copy per insert, but the caller must not modify
+// a vector once it's inserted. By default vectors are copied into contiguous
+// chunks owned by the LSHForest
+func WithZeroCopy() Option {
+	return func(c *config) {
+		c.zeroCopy = true
+	}
+}
+
+// QueryOption configures a single query
+type QueryOption func(*queryConfig)
+
+type queryConfig struct {
+	stats      *QueryStats
+	partial    bool
+	multiplier uint
+	probes     uint
+}
+
+// Stats records how much work the query did in stats
+func Stats(stats *QueryStats) QueryOption {
+	return func(c *queryConfig) {
+		c.stats = stats
+	}
+}
+
+// Partial makes a query whose context is done return the best results it has
+// found so far instead of the context's error. If the context is done before
+// the query has found all of its candidates, only the first 256 it found are
+// ranked, so it returns soon after the context's deadline
+func Partial() QueryOption {
+	return func(c *queryConfig) {
+		c.partial = true
+	}
+}
+
+// Multiplier sets the candidate multiplier c of the query, overriding the
+// one set by WithMultiplier. The trees are ascended until at least c * l
+// candidates are found, as well as m elements
+func Multiplier(multiplier uint) QueryOption {
+	return func(c *queryConfig) {
+		c.multiplier = multiplier
+	}
+}
+
+// Probes sets the number of probes of each tree of the query, overriding the
+// number set by WithProbes
+func Probes(probes uint) QueryOption {
+	return func(c *queryConfig) {
+		c.probes = probes
+	}
+}
+
+// newQueryConfig applies opts to c, the defaults of a query
+func newQueryConfig(c queryConfig, opts []QueryOption) queryConfig {
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// record stores the number of candidates compared by the query, and whether
+// its results are partial, in c.stats
+func (c queryConfig) record(candidates int, partial bool) {
+	if c.stats != nil {
+		c.stats.Candidates = uint(candidates)
+		c.stats.Partial = partial
+	}
+}
+
+// recordProbes stores the number of subtrees the query probed in c.stats
+func (c queryConfig) recordProbes(probes int) {
+	if c.stats != nil {
+		c.stats.Probes = uint(probes)
+	}
+}
+
+// recordCollected stores the number of candidates collected from the trees,
+// the sum of the number of trees each was found in, in c.stats
+func (c queryConfig) recordCollected(trees map[uint]uint) {
+	if c.stats != nil {
+		c.stats.Collected = 0
+		for _, n := range trees {
+			c.stats.Collected += n
+		}
+	}
+}
+
+// WithMultiplier sets the default candidate multiplier c of queries, which
+// ascend the trees until at least c * l candidates are found, counting an
+// element once for each tree it's found in, as well as m elements. Raising c
+// trades latency for recall. It's 0 by default. Multiplier overrides it for a
+// single query
+func WithMultiplier(multiplier uint) Option {
+	return func(c *config) {
+		c.multiplier = multiplier
+	}
+}
+
+// WithProbes sets the default number of probes of each tree. Besides the
+// subtree its hash descends to, a query visits the subtree reached by flipping
+// each of the probes least confident bits of its hash, those of the
+// hyperplanes or bucket edges nearest the query vector. This finds more of the
+// near neighbors in each tree, so fewer trees reach the same recall. It's 0 by
+// default, and Jaccard forests, whose minhashes have no margins, ignore it.
+// Probes overrides it for a single query
+func WithProbes(probes uint) Option {
+	return func(c *config) {
+		c.probes = probes
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{width: 4}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// treeSeeds returns the seed of each of the l trees' hasher
+func (c config) treeSeeds(l uint) []int64 {
+	if c.seeds != nil {
+		if uint(len(*c.seeds)) != l {
+			panic("lshforest WithTreeSeeds() needs a seed for each tree")
+		}
+		return append([]int64{}, *c.seeds...)
+	}
+	random := rand.Int63
+	if c.source != nil {
+		random = rand.New(c.source).Int63
+	}
+	seeds := make([]int64, l)
+	for i := range seeds {
+		seeds[i] = random()
+	}
+	return seeds
+}
@@ -0,0 +1,63 @@
+package lshforest
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func hashes(f *LSHForest[interface{}], vector *[]float64) [][]uint8 {
+	var hashes [][]uint8
+	for _, hasher := range f.hashers {
+		var bits []uint8
+		for _, bit := range hasher.Hash(vector).Bits() {
+			bits = append(bits, uint8(bit))
+		}
+		hashes = append(hashes, bits)
+	}
+	return hashes
+}
+
+func TestWithSeed(t *testing.T) {
+	vector := []float64{1, -2, 0.5, 4}
+	for _, metric := range []uint{Cosine, Jaccard, Euclidean} {
+		f1 := New(4, 32, 4, metric, WithSeed(7))
+		f2 := New(4, 32, 4, metric, WithSource(rand.NewSource(7)))
+		f3 := New(4, 32, 4, metric, WithTreeSeeds(f1.Seeds()))
+		if !reflect.DeepEqual(f1.Seeds(), f2.Seeds()) {
+			t.Fatalf("seeds differ: (%v) (%v)", f1.Seeds(), f2.Seeds())
+		}
+		if !reflect.DeepEqual(hashes(f1, &vector), hashes(f2, &vector)) ||
+			!reflect.DeepEqual(hashes(f1, &vector), hashes(f3, &vector)) {
+			t.Fatal("forests with the same seed hash differently")
+		}
+
+		f4 := New(4, 32, 4, metric, WithSeed(8))
+		if reflect.DeepEqual(f1.Seeds(), f4.Seeds()) {
+			t.Fatal("forests with different seeds have the same seeds")
+		}
+	}
+}
+
+func TestWithTreeSeedsPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.FailNow()
+		}
+	}()
+	_ = New(4, 32, 4, Cosine, WithTreeSeeds([]int64{1, 2}))
+}
+
+func TestWithBucketWidthPanic(t *testing.T) {
+	for _, width := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Fatalf("width (%v) didn't panic", width)
+				}
+			}()
+			_ = New(4, 32, 4, Euclidean, WithBucketWidth(width))
+		}()
+	}
+}
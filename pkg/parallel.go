@@ -0,0 +1,48 @@
+package lshforest
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// hashBatch is the number of vectors InsertAll hashes per batch
+const hashBatch = 64
+
+// parallel calls fn(i) for each i in [0, n) on up to f.workers goroutines,
+// which take batch consecutive i's at a time. It returns once every call has
+// returned
+func (f *LSHForest[V]) parallel(n, batch int, fn func(i int)) {
+	workers := f.workers
+	if batches := (n + batch - 1) / batch; batches < workers {
+		workers = batches
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	var next int64 // the first i of the next batch
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				start := int(atomic.AddInt64(&next, int64(batch))) - batch
+				if start >= n {
+					return
+				}
+				end := start + batch
+				if end > n {
+					end = n
+				}
+				for i := start; i < end; i++ {
+					fn(i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
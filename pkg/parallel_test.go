@@ -0,0 +1,78 @@
+package lshforest
+
+import (
+	"math/rand"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParallel(t *testing.T) {
+	for _, workers := range []int{0, 1, 3, 8} {
+		for _, n := range []int{0, 1, 10, 1000} {
+			f := &LSHForest[interface{}]{workers: workers}
+			calls := make([]int32, n)
+			f.parallel(n, 7, func(i int) {
+				atomic.AddInt32(&calls[i], 1)
+			})
+			for i, c := range calls {
+				if c != 1 {
+					t.Fatalf("workers: (%v) n: (%v) | fn(%v) called (%v) times",
+						workers, n, i, c)
+				}
+			}
+		}
+	}
+}
+
+func TestWithWorkers(t *testing.T) {
+	const dim = 16
+	r := rand.New(rand.NewSource(1))
+	vectors, values := randomVectors(r, 300, dim)
+
+	sequential := New(8, 24, dim, Cosine, WithSeed(3))
+	parallel := New(8, 24, dim, Cosine, WithSeed(3), WithWorkers(0),
+		WithConcurrency())
+	extra := randomVector(r, dim)
+	for _, f := range []*LSHForest[interface{}]{sequential, parallel} {
+		if err := f.InsertAll(&vectors, &values); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Insert(&vectors[0], -1); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Insert(&extra, 300); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Delete(5); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		query := randomVector(r, dim)
+		expected, err := sequential.QueryResults(&query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := parallel.QueryResults(&query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Fatalf("expected: (%+v) | got: (%+v)", *expected, *got)
+		}
+	}
+}
+
+func TestInsertAllInvalid(t *testing.T) {
+	vectors := [][]float64{{1, 2, 3}, {0, 0, 0}}
+	values := []interface{}{0, 1}
+	lshforest := NewDefault(3, Cosine, WithWorkers(2))
+	if err := lshforest.InsertAll(&vectors, &values); err != ErrNonZero {
+		t.Fatal(err)
+	}
+	if _, err := lshforest.Query(&vectors[0], 1); err != ErrEmptyForest {
+		t.Fatal("InsertAll inserted vectors before an invalid vector")
+	}
+}
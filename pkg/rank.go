@@ -0,0 +1,92 @@
+package lshforest
+
+import (
+	"container/heap"
+	"context"
+	"github.com/justinfargnoli/lshforest/pkg/lshtree"
+)
+
+// rankBatch is the number of similarities topElements computes between checks
+// of whether its context is done
+const rankBatch = 256
+
+// ranked is a candidate along with its similarity to the query vector and its
+// position among the candidates
+type ranked[V any] struct {
+	element    lshtree.Element[V]
+	similarity float64
+	index      int
+}
+
+// worse returns whether r1 ranks below r2. Of equally similar candidates, the
+// one found later ranks lower
+func worse[V any](r1, r2 ranked[V]) bool {
+	if r1.similarity != r2.similarity {
+		return r1.similarity < r2.similarity
+	}
+	return r1.index > r2.index
+}
+
+// rankedHeap is a min-heap whose root is the lowest ranked candidate
+type rankedHeap[V any] []ranked[V]
+
+func (h rankedHeap[V]) Len() int           { return len(h) }
+func (h rankedHeap[V]) Less(i, j int) bool { return worse(h[i], h[j]) }
+func (h rankedHeap[V]) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *rankedHeap[V]) Push(x interface{}) {
+	*h = append(*h, x.(ranked[V]))
+}
+
+func (h *rankedHeap[V]) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// topElements returns the m, or fewer, elements most similar to the query
+// vector whose similarity is at least minSimilarity, sorted by similarity,
+// along with their similarities. similarity returns the similarity of an
+// element to the query vector. Only a heap of the m best elements so far is
+// kept, so the elements aren't all sorted. Equally similar elements keep their
+// order. If ctx is done first, only the elements compared with the query
+// vector by then are ranked and ctx.Err() is returned. scored is the number of
+// elements compared with the query vector
+func topElements[V any](ctx context.Context, elements *[]lshtree.Element[V],
+	similarity func(lshtree.Element[V]) float64, m uint,
+	minSimilarity float64) (top *[]lshtree.Element[V], similarities *[]float64,
+	scored int, err error) {
+	size := len(*elements)
+	if uint(size) > m {
+		size = int(m)
+	}
+	h := make(rankedHeap[V], 0, size)
+	for i, element := range *elements {
+		if i%rankBatch == 0 {
+			if err = ctx.Err(); err != nil {
+				break
+			}
+		}
+		scored++
+		r := ranked[V]{element: element,
+			similarity: similarity(element), index: i}
+		if r.similarity < minSimilarity || m == 0 {
+			continue
+		}
+		if uint(len(h)) < m {
+			heap.Push(&h, r)
+		} else if worse(h[0], r) {
+			h[0] = r
+			heap.Fix(&h, 0)
+		}
+	}
+
+	sorted := make([]lshtree.Element[V], len(h))
+	sortedSimilarities := make([]float64, len(h))
+	for i := len(h) - 1; i >= 0; i-- {
+		r := heap.Pop(&h).(ranked[V])
+		sorted[i], sortedSimilarities[i] = r.element, r.similarity
+	}
+	return &sorted, &sortedSimilarities, scored, err
+}
@@ -0,0 +1,90 @@
+package lshforest
+
+import (
+	"context"
+	"github.com/justinfargnoli/lshforest/pkg/hash"
+	"github.com/justinfargnoli/lshforest/pkg/lshtree"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestTopElements(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	var elements []lshtree.Element[interface{}]
+	for i := 0; i < 2*rankBatch+1; i++ {
+		vector := randomVector(r, 4)
+		elements = append(elements, lshtree.NewElement[interface{}](uint(i),
+			hash.Signature{}, &vector, i))
+	}
+	// ties keep their order
+	elements = append(elements, elements[0], elements[1], elements[0])
+	query := randomVector(r, 4)
+	similarity := func(element lshtree.Element[interface{}]) float64 {
+		return cosine(&query, element.Vector)
+	}
+
+	all, similarities, scored, err := topElements(context.Background(),
+		&elements, similarity, uint(len(elements)), math.Inf(-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scored != len(elements) || len(*all) != len(elements) {
+		t.Fatalf("expected: (%v) ranked elements | got: (%v) of (%v)",
+			len(elements), len(*all), scored)
+	}
+	expected := append([]lshtree.Element[interface{}]{}, elements...)
+	sort.SliceStable(expected, func(i, j int) bool {
+		return cosine(&query, expected[i].Vector) >
+			cosine(&query, expected[j].Vector)
+	})
+	for i := range expected {
+		if (*all)[i].ID != expected[i].ID ||
+			(*similarities)[i] != cosine(&query, expected[i].Vector) {
+			t.Fatalf("expected: (%v) at (%v) | got: (%v)", expected[i].ID, i,
+				(*all)[i].ID)
+		}
+	}
+
+	for _, m := range []uint{0, 1, 10} {
+		top, _, _, err := topElements(context.Background(), &elements,
+			similarity, m, math.Inf(-1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*top, (*all)[:m]) {
+			t.Fatalf("expected: (%v) | got: (%v)", (*all)[:m], *top)
+		}
+	}
+
+	minSimilarity := (*similarities)[20]
+	above, _, _, err := topElements(context.Background(), &elements,
+		similarity, uint(len(elements)), minSimilarity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, element := range *above {
+		if cosine(&query, element.Vector) < minSimilarity ||
+			element.ID != (*all)[i].ID {
+			t.Fatalf("unexpected element (%v) at (%v)", element.ID, i)
+		}
+	}
+
+	top, similarities, scored, err := topElements(
+		&countdownContext{Context: context.Background(), n: 2}, &elements,
+		similarity, 10, math.Inf(-1))
+	if err != context.Canceled {
+		t.Fatalf("expected: (%v) | got: (%v)", context.Canceled, err)
+	}
+	if scored != 2*rankBatch || len(*top) != 10 {
+		t.Fatalf("expected: (%v) scored elements | got: (%v)", 2*rankBatch,
+			scored)
+	}
+	if !sort.SliceIsSorted(*similarities, func(i, j int) bool {
+		return (*similarities)[i] > (*similarities)[j]
+	}) {
+		t.Fatalf("similarities aren't sorted: (%v)", *similarities)
+	}
+}
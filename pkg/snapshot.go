@@ -0,0 +1,537 @@
+package lshforest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"github.com/justinfargnoli/lshforest/pkg/hash"
+	"github.com/justinfargnoli/lshforest/pkg/lshtree"
+	"github.com/justinfargnoli/lshforest/pkg/sparse"
+	"hash/crc32"
+	"io"
+	"math"
+	"sync"
+)
+
+// snapshotMagic begins every snapshot written by WriteTo
+var snapshotMagic = [4]byte{'L', 'S', 'H', 'F'}
+
+// the kinds of vector of the elements of a snapshot
+const (
+	denseVector   = 0
+	sparseVector  = 1
+	float32Vector = 2
+)
+
+// snapshotVersion is the version of the snapshot format written by WriteTo
+const snapshotVersion = uint16(8)
+
+var (
+	// ErrSnapshot is thrown when ReadFrom reads something other than a
+	// snapshot written by WriteTo
+	ErrSnapshot = errors.New("not an lshforest snapshot")
+	// ErrSnapshotVersion is thrown when ReadFrom reads a snapshot of an
+	// unsupported version
+	ErrSnapshotVersion = errors.New("unsupported lshforest snapshot version")
+	// ErrChecksum is thrown when the checksum of a snapshot doesn't match its
+	// contents
+	ErrChecksum = errors.New("lshforest snapshot checksum mismatch")
+	// ErrValueType is thrown when ReadFrom decodes a value which doesn't have
+	// the type of the values of the LSHForest, such as from a snapshot of an
+	// LSHForest with values of another type
+	ErrValueType = errors.New("lshforest snapshot value has the wrong type")
+)
+
+// ValueCodec encodes and decodes the values of an LSHForest in WriteTo and
+// ReadFrom
+type ValueCodec interface {
+	EncodeValue(interface{}) ([]byte, error)
+	DecodeValue([]byte) (interface{}, error)
+}
+
+// GobCodec is a ValueCodec which uses encoding/gob on values boxed in
+// interface{}. Values of types other than gob's basic types must be registered
+// with gob.Register. Without a ValueCodec, an LSHForest encodes its values with
+// encoding/gob as their type V instead, which needs no registration unless V
+// is an interface type
+type GobCodec struct{}
+
+// EncodeValue encodes value with encoding/gob
+func (GobCodec) EncodeValue(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&value)
+	return buf.Bytes(), err
+}
+
+// DecodeValue decodes a value encoded by EncodeValue
+func (GobCodec) DecodeValue(data []byte) (interface{}, error) {
+	var value interface{}
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+// gobValue is how a value is encoded without a ValueCodec. The value is a
+// field, rather than encoded itself, so gob accepts a nil pointer
+type gobValue[V any] struct {
+	Value V
+}
+
+// encodeValue encodes the value with the LSHForest's ValueCodec, or as a
+// gobValue if it has none
+func (f *LSHForest[V]) encodeValue(value V) ([]byte, error) {
+	if f.codec != nil {
+		return f.codec.EncodeValue(value)
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(gobValue[V]{Value: value})
+	return buf.Bytes(), err
+}
+
+// decodeValue decodes a value encoded by encodeValue
+func (f *LSHForest[V]) decodeValue(data []byte) (V, error) {
+	var value gobValue[V]
+	if f.codec == nil {
+		// the snapshot's checksum is checked before the error is returned,
+		// so the value was encoded as another type
+		err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+		if err != nil {
+			return value.Value, ErrValueType
+		}
+		return value.Value, nil
+	}
+	decoded, err := f.codec.DecodeValue(data)
+	if err != nil || decoded == nil {
+		return value.Value, err
+	}
+	v, ok := decoded.(V)
+	if !ok {
+		return value.Value, ErrValueType
+	}
+	return v, nil
+}
+
+// WriteTo writes a snapshot of the LSHForest to w: a versioned header of its
+// parameters and the defaults of its queries, the hashers, vectors, values and
+// trees, and a CRC-32 checksum of all of it
+func (f *LSHForest[V]) WriteTo(w io.Writer) (int64, error) {
+	if f.concurrent {
+		f.writers.Lock()
+		defer f.writers.Unlock()
+	}
+	sw := &snapshotWriter{w: bufio.NewWriter(w)}
+	sw.Write(snapshotMagic[:])
+	sw.fixed(uint64(snapshotVersion), 2)
+	for _, x := range []uint{f.metric, uint(len(f.trees)), f.maxK, f.vecDim,
+		f.nextID, f.backend, f.multiplier, f.probes} {
+		sw.uvarint(uint64(x))
+	}
+	for _, seed := range f.seeds {
+		sw.fixed(uint64(seed), 8)
+	}
+
+	for _, hasher := range f.hashers {
+		data, err := hasher.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return sw.n, err
+		}
+		sw.bytes(data)
+	}
+
+	ids := sortedIDs(f.entries)
+	sw.uvarint(uint64(len(ids)))
+	for _, id := range ids {
+		entry := f.entries[id]
+		sw.uvarint(uint64(id))
+		switch {
+		case entry.Sparse != nil:
+			sw.uvarint(sparseVector)
+			sw.uvarint(uint64(entry.Sparse.Len()))
+			for i, index := range entry.Sparse.Indices() {
+				sw.uvarint(uint64(index))
+				sw.fixed(math.Float64bits(entry.Sparse.Values()[i]), 8)
+			}
+		case entry.Vector32 != nil:
+			sw.uvarint(float32Vector)
+			for _, v := range *entry.Vector32 {
+				sw.fixed(uint64(math.Float32bits(v)), 4)
+			}
+		default:
+			sw.uvarint(denseVector)
+			for _, v := range *entry.Vector {
+				sw.fixed(math.Float64bits(v), 8)
+			}
+		}
+		data, err := f.encodeValue(entry.Value)
+		if err != nil {
+			return sw.n, err
+		}
+		sw.bytes(data)
+	}
+
+	for _, tree := range f.trees {
+		if err := tree.(encoder).Encode(sw); err != nil {
+			return sw.n, err
+		}
+	}
+
+	if sw.err != nil {
+		return sw.n, sw.err
+	}
+	var checksum [4]byte
+	binary.LittleEndian.PutUint32(checksum[:], sw.crc)
+	n, err := sw.w.Write(checksum[:])
+	sw.n += int64(n)
+	if err != nil {
+		return sw.n, err
+	}
+	return sw.n, sw.w.Flush()
+}
+
+// ReadFrom replaces the LSHForest with the snapshot written by WriteTo which
+// it reads from r. The LSHForest is unchanged if an error is returned. Values
+// are decoded with the ValueCodec the LSHForest was constructed with, so a
+// snapshot written without one can be read into a new(LSHForest[V]) of the
+// same V. A value which can't be decoded is reported once the checksum
+// matches, so a corrupt snapshot isn't mistaken for one of another V. The
+// defaults of queries set WithMultiplier and WithProbes are read from the
+// snapshot, while the other options stay the LSHForest's own. Nothing
+// past the end of the snapshot is read from r. If r doesn't implement
+// io.ByteReader, it's read from a byte at a time where the snapshot has
+// varints, so wrapping r in a bufio.Reader, if that's allowed to read ahead,
+// reads faster
+func (f *LSHForest[V]) ReadFrom(r io.Reader) (int64, error) {
+	br, ok := r.(byteReader)
+	if !ok {
+		br = oneByteReader{r}
+	}
+	sr := &snapshotReader{r: br}
+	forest, valueErr, err := f.readSnapshot(sr)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = ErrSnapshot
+	}
+	if err != nil {
+		return sr.n, err
+	}
+
+	var checksum [4]byte
+	n, err := io.ReadFull(sr.r, checksum[:])
+	sr.n += int64(n)
+	if err != nil {
+		return sr.n, ErrSnapshot
+	}
+	if binary.LittleEndian.Uint32(checksum[:]) != sr.crc {
+		return sr.n, ErrChecksum
+	}
+	if valueErr != nil {
+		return sr.n, valueErr
+	}
+	f.trees, f.hashers, f.similarity = forest.trees, forest.hashers,
+		forest.similarity
+	f.seeds, f.metric, f.backend = forest.seeds, forest.metric, forest.backend
+	f.maxK, f.vecDim = forest.maxK, forest.vecDim
+	f.multiplier, f.probes = forest.multiplier, forest.probes
+	f.entries, f.nextID = forest.entries, forest.nextID
+	f.treeLocks = make([]sync.RWMutex, len(f.trees))
+	return sr.n, nil
+}
+
+// readSnapshot reads the snapshot up to its checksum. The first error decoding
+// a value is returned as valueErr, as it's only reported if the checksum
+// matches
+func (f *LSHForest[V]) readSnapshot(sr *snapshotReader) (
+	forest *LSHForest[V], valueErr error, err error) {
+	var magic [4]byte
+	if _, err := io.ReadFull(sr, magic[:]); err != nil {
+		return nil, nil, err
+	}
+	if magic != snapshotMagic {
+		return nil, nil, ErrSnapshot
+	}
+	version, err := sr.fixed(2)
+	if err != nil {
+		return nil, nil, err
+	}
+	if uint16(version) != snapshotVersion {
+		return nil, nil, ErrSnapshotVersion
+	}
+	var header [8]uint
+	for i := range header {
+		x, err := binary.ReadUvarint(sr)
+		if err != nil {
+			return nil, nil, err
+		}
+		header[i] = uint(x)
+	}
+	forest = &LSHForest[V]{metric: header[0], maxK: header[2], vecDim: header[3],
+		nextID: header[4], backend: header[5], multiplier: header[6],
+		probes: header[7], entries: make(map[uint]entry[V]), codec: f.codec}
+	if forest.metric > Euclidean || forest.backend > SortedBackend {
+		return nil, nil, ErrSnapshot
+	}
+	forest.similarity = similarity(forest.metric)
+	l := header[1]
+
+	for i := uint(0); i < l; i++ {
+		seed, err := sr.fixed(8)
+		if err != nil {
+			return nil, nil, err
+		}
+		forest.seeds = append(forest.seeds, int64(seed))
+	}
+
+	for i := uint(0); i < l; i++ {
+		data, err := sr.bytes()
+		if err != nil {
+			return nil, nil, err
+		}
+		var hasher interface {
+			hash.Hasher
+			encoding.BinaryUnmarshaler
+			Bits() uint
+		}
+		switch forest.metric {
+		case Jaccard:
+			hasher = &hash.Minhash{}
+		case Euclidean:
+			hasher = &hash.PStable{}
+		default:
+			hasher = &hash.Online{}
+		}
+		if err := hasher.UnmarshalBinary(data); err != nil ||
+			hasher.Bits() != forest.maxK {
+			return nil, nil, ErrSnapshot
+		}
+		// minhash treats a vector as a set, so only it hashes any dimension
+		if dense, ok := hasher.(interface{ Dim() uint }); ok &&
+			dense.Dim() != forest.vecDim {
+			return nil, nil, ErrSnapshot
+		}
+		forest.hashers = append(forest.hashers, hasher)
+	}
+
+	count, err := binary.ReadUvarint(sr)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := uint64(0); i < count; i++ {
+		id, err := binary.ReadUvarint(sr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := forest.entries[uint(id)]; ok || uint(id) >= forest.nextID {
+			return nil, nil, ErrSnapshot
+		}
+		p, err := forest.readPoint(sr)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := sr.bytes()
+		if err != nil {
+			return nil, nil, err
+		}
+		value, err := forest.decodeValue(data)
+		if err != nil && valueErr == nil {
+			valueErr = err
+		}
+		entry := newEntry(p, value)
+		entry.hashes = make([]hash.Signature, l)
+		forest.entries[uint(id)] = entry
+	}
+
+	for i := uint(0); i < l; i++ {
+		// each element is in every tree once, and its entry keeps its hash
+		resolve := func(id uint, signature hash.Signature) (lshtree.Element[V],
+			error) {
+			entry, ok := forest.entries[id]
+			if !ok || entry.hashes[i].Len() != 0 ||
+				signature.Len() != forest.maxK {
+				return lshtree.Element[V]{}, ErrSnapshot
+			}
+			entry.hashes[i] = signature
+			return entry.element(id, signature), nil
+		}
+		tree := newTree[V](forest.backend)
+		if err := tree.(decoder[V]).Decode(sr, resolve); err != nil {
+			return nil, nil, err
+		}
+		forest.trees = append(forest.trees, tree)
+	}
+	for _, entry := range forest.entries {
+		for _, signature := range entry.hashes {
+			if signature.Len() == 0 {
+				return nil, nil, ErrSnapshot
+			}
+		}
+	}
+	return forest, valueErr, nil
+}
+
+// readPoint reads a vector written by WriteTo
+func (f *LSHForest[V]) readPoint(sr *snapshotReader) (point, error) {
+	kind, err := binary.ReadUvarint(sr)
+	if err != nil {
+		return point{}, err
+	}
+	switch kind {
+	case denseVector:
+		var vector []float64
+		for i := uint(0); i < f.vecDim; i++ {
+			bits, err := sr.fixed(8)
+			if err != nil {
+				return point{}, err
+			}
+			vector = append(vector, math.Float64frombits(bits))
+		}
+		return point{dense: &vector}, nil
+	case float32Vector:
+		var vector []float32
+		for i := uint(0); i < f.vecDim; i++ {
+			bits, err := sr.fixed(4)
+			if err != nil {
+				return point{}, err
+			}
+			vector = append(vector, math.Float32frombits(uint32(bits)))
+		}
+		return point{dense32: &vector}, nil
+	case sparseVector:
+		count, err := binary.ReadUvarint(sr)
+		if err != nil {
+			return point{}, err
+		}
+		var indices []uint
+		var values []float64
+		for i := uint64(0); i < count; i++ {
+			index, err := binary.ReadUvarint(sr)
+			if err != nil {
+				return point{}, err
+			}
+			bits, err := sr.fixed(8)
+			if err != nil {
+				return point{}, err
+			}
+			indices = append(indices, uint(index))
+			values = append(values, math.Float64frombits(bits))
+		}
+		vector, err := sparse.New(indices, values)
+		if err != nil || vector.Dim() > f.vecDim {
+			return point{}, ErrSnapshot
+		}
+		return point{sparse: vector}, nil
+	}
+	return point{}, ErrSnapshot
+}
+
+// encoder is implemented by each lshtree.LSHTree backend
+type encoder interface {
+	Encode(io.Writer) error
+}
+
+// decoder is implemented by each lshtree.LSHTree backend
+type decoder[V any] interface {
+	Decode(io.ByteReader,
+		func(id uint, hash hash.Signature) (lshtree.Element[V], error)) error
+}
+
+// snapshotWriter writes to w while counting and checksumming what's written.
+// Once a write fails, every later write is skipped and err is kept
+type snapshotWriter struct {
+	w   *bufio.Writer
+	crc uint32
+	n   int64
+	err error
+}
+
+func (s *snapshotWriter) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	n, err := s.w.Write(p)
+	s.crc = crc32.Update(s.crc, crc32.IEEETable, p[:n])
+	s.n += int64(n)
+	s.err = err
+	return n, err
+}
+
+func (s *snapshotWriter) uvarint(x uint64) {
+	var b [binary.MaxVarintLen64]byte
+	s.Write(b[:binary.PutUvarint(b[:], x)])
+}
+
+// fixed writes the size lowest bytes of x in little endian order
+func (s *snapshotWriter) fixed(x uint64, size int) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], x)
+	s.Write(b[:size])
+}
+
+// bytes writes the length of b followed by b
+func (s *snapshotWriter) bytes(b []byte) {
+	s.uvarint(uint64(len(b)))
+	s.Write(b)
+}
+
+// snapshotReader reads from r while counting and checksumming what's read
+// byteReader is a reader which can read a single byte
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// oneByteReader is a byteReader which reads a single byte by reading a single
+// byte from r, so it never reads ahead of what's asked of it
+type oneByteReader struct {
+	io.Reader
+}
+
+func (r oneByteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r, b[:])
+	return b[0], err
+}
+
+type snapshotReader struct {
+	r   byteReader
+	crc uint32
+	n   int64
+}
+
+func (s *snapshotReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.crc = crc32.Update(s.crc, crc32.IEEETable, p[:n])
+	s.n += int64(n)
+	return n, err
+}
+
+func (s *snapshotReader) ReadByte() (byte, error) {
+	b, err := s.r.ReadByte()
+	if err == nil {
+		s.crc = crc32.Update(s.crc, crc32.IEEETable, []byte{b})
+		s.n++
+	}
+	return b, err
+}
+
+// fixed reads size bytes written by snapshotWriter.fixed
+func (s *snapshotReader) fixed(size int) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(s, b[:size]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b[:]), nil
+}
+
+// bytes reads bytes written by snapshotWriter.bytes
+func (s *snapshotReader) bytes() ([]byte, error) {
+	length, err := binary.ReadUvarint(s)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, s, int64(length)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,388 @@
+package lshforest
+
+import (
+	"bytes"
+	"github.com/justinfargnoli/lshforest/pkg/hash"
+	"github.com/justinfargnoli/lshforest/pkg/sparse"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func snapshot(t *testing.T, f io.WriterTo) []byte {
+	var buf bytes.Buffer
+	n, err := f.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("wrote (%v) bytes | reported (%v)", buf.Len(), n)
+	}
+	return buf.Bytes()
+}
+
+func TestSnapshot(t *testing.T) {
+	vectors := [][]float64{
+		{1, 2, 3},
+		{1.1, 4, -3},
+		{1, -2, 3},
+		{1, 1, 1},
+		{-1, 2, 3},
+		{0.1, 0.2, 0.4},
+	}
+	values := []interface{}{"a", 1, 2.5, nil, int64(4), "f"}
+	for _, metric := range []uint{Cosine, Jaccard, Euclidean} {
+		for _, backend := range []uint{TrieBackend, SortedBackend} {
+			testSnapshot(t, &vectors, &values, metric, backend)
+		}
+	}
+}
+
+func testSnapshot(t *testing.T, vectors *[][]float64, values *[]interface{},
+	metric, backend uint) {
+	lshforest := New(4, 16, 3, metric, WithSeed(1), WithBackend(backend))
+	if err := lshforest.InsertAll(vectors, values); err != nil {
+		t.Fatal(err)
+	}
+	if err := lshforest.Delete(2); err != nil {
+		t.Fatal(err)
+	}
+	data := snapshot(t, lshforest)
+
+	loaded := new(LSHForest[interface{}])
+	n, err := loaded.ReadFrom(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Fatalf("read (%v) bytes | reported (%v)", len(data), n)
+	}
+	if !bytes.Equal(snapshot(t, loaded), data) {
+		t.Fatal("snapshot of the loaded forest differs")
+	}
+	if !reflect.DeepEqual(loaded.Seeds(), lshforest.Seeds()) {
+		t.Fatal("seeds differ")
+	}
+
+	for i := range *vectors {
+		expected, err := lshforest.QueryResults(&(*vectors)[i], 6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := loaded.QueryResults(&(*vectors)[i], 6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Fatalf("expected: (%+v) | got: (%+v)", *expected, *got)
+		}
+	}
+
+	if err := loaded.Insert(&[]float64{3, 2, 1}, "g"); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Delete(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Delete(6); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotInvalid(t *testing.T) {
+	lshforest := insertAll(t)
+	data := snapshot(t, lshforest)
+
+	loaded := NewDefault(3, Cosine)
+	if _, err := loaded.ReadFrom(bytes.NewReader([]byte("LSHX"))); err != ErrSnapshot {
+		t.Fatal(err)
+	}
+	if _, err := loaded.ReadFrom(bytes.NewReader(data[:len(data)-1])); err != ErrSnapshot {
+		t.Fatal(err)
+	}
+
+	corrupt := append([]byte{}, data...)
+	corrupt[4] = 0xff
+	if _, err := loaded.ReadFrom(bytes.NewReader(corrupt)); err != ErrSnapshotVersion {
+		t.Fatal(err)
+	}
+
+	corrupt = append([]byte{}, data...)
+	corrupt[len(corrupt)-5] ^= 0xff
+	if _, err := loaded.ReadFrom(bytes.NewReader(corrupt)); err == nil {
+		t.Fatal("read a corrupt snapshot")
+	}
+	corrupt = append([]byte{}, data...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	if _, err := loaded.ReadFrom(bytes.NewReader(corrupt)); err != ErrChecksum {
+		t.Fatal(err)
+	}
+
+	if _, err := loaded.Query(&[]float64{1, 1, 1}, 1); err != ErrEmptyForest {
+		t.Fatal("a failed ReadFrom changed the forest")
+	}
+}
+
+func TestSnapshotHasher(t *testing.T) {
+	hashers := []hash.Hasher{hash.NewOnline(16, 1), hash.NewOnline(15, 3)}
+	for _, hasher := range hashers {
+		lshforest := New(4, 16, 3, Cosine)
+		lshforest.hashers[0] = hasher
+		data := snapshot(t, lshforest)
+		if _, err := new(LSHForest[interface{}]).ReadFrom(
+			bytes.NewReader(data)); err != ErrSnapshot {
+			t.Fatal(err)
+		}
+	}
+
+	// the trees hold hashes of 16 bits, and the header and hashers 15
+	lshforest := New(4, 16, 3, Cosine)
+	lshforest.maxK = 15
+	if err := lshforest.Insert(&[]float64{1, 2, 3}, "a"); err != nil {
+		t.Fatal(err)
+	}
+	lshforest.hashers = []hash.Hasher{hash.NewOnline(15, 3),
+		hash.NewOnline(15, 3), hash.NewOnline(15, 3), hash.NewOnline(15, 3)}
+	data := snapshot(t, lshforest)
+	if _, err := new(LSHForest[interface{}]).ReadFrom(
+		bytes.NewReader(data)); err != ErrSnapshot {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotQueryDefaults(t *testing.T) {
+	const dim = 8
+	r := rand.New(rand.NewSource(9))
+	vectors, values := randomVectors(r, 300, dim)
+	lshforest := New(2, 16, dim, Cosine, WithSeed(1), WithProbes(3),
+		WithMultiplier(2))
+	if err := lshforest.InsertAll(&vectors, &values); err != nil {
+		t.Fatal(err)
+	}
+	loaded := new(LSHForest[interface{}])
+	if _, err := loaded.ReadFrom(bytes.NewReader(snapshot(t,
+		lshforest))); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		var expectedStats, stats QueryStats
+		expected, err := lshforest.QueryResults(&vectors[i], 5,
+			Stats(&expectedStats))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := loaded.QueryResults(&vectors[i], 5, Stats(&stats))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, got) || expectedStats != stats {
+			t.Fatalf("expected: (%+v) (%+v) | got: (%+v) (%+v)", *expected,
+				expectedStats, *got, stats)
+		}
+	}
+}
+
+type stringCodec struct{}
+
+func (stringCodec) EncodeValue(value interface{}) ([]byte, error) {
+	return []byte(value.(string)), nil
+}
+
+func (stringCodec) DecodeValue(data []byte) (interface{}, error) {
+	return string(data), nil
+}
+
+func TestSnapshotValueCodec(t *testing.T) {
+	lshforest := NewDefault(3, Cosine, WithValueCodec(stringCodec{}))
+	if err := lshforest.Insert(&[]float64{1, 2, 3}, "a"); err != nil {
+		t.Fatal(err)
+	}
+	data := snapshot(t, lshforest)
+
+	loaded := NewDefault(1, Cosine, WithValueCodec(stringCodec{}))
+	if _, err := loaded.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	value, err := loaded.Query(&[]float64{1, 2, 3}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*value)[0].(string) != "a" {
+		t.Fatalf("expected: (a) | got: (%v)", (*value)[0])
+	}
+}
+
+func TestSnapshotIDs(t *testing.T) {
+	lshforest := NewDefault(3, Cosine)
+	if err := lshforest.InsertWithID(100, &[]float64{1, 2, 3}, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := lshforest.InsertWithID(7, &[]float64{3, 2, 1}, "b"); err != nil {
+		t.Fatal(err)
+	}
+	loaded := new(LSHForest[interface{}])
+	if _, err := loaded.ReadFrom(bytes.NewReader(snapshot(t, lshforest))); err != nil {
+		t.Fatal(err)
+	}
+	if ids := loaded.IDs(); !reflect.DeepEqual(ids, []uint{7, 100}) {
+		t.Fatalf("expected: ([7 100]) | got: (%v)", ids)
+	}
+	if err := loaded.Insert(&[]float64{1, 1, 1}, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Contains(101) {
+		t.Fatalf("expected: (101) to be inserted | got: (%v)", loaded.IDs())
+	}
+}
+
+func TestSnapshotTyped(t *testing.T) {
+	lshforest := NewDefaultTyped[string](3, Cosine)
+	if err := lshforest.Insert(&[]float64{1, 2, 3}, "a"); err != nil {
+		t.Fatal(err)
+	}
+	data := snapshot(t, lshforest)
+
+	loaded := new(LSHForest[string])
+	if _, err := loaded.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	values, err := loaded.Query(&[]float64{1, 2, 3}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*values)[0] != "a" {
+		t.Fatalf("expected: (a) | got: (%v)", (*values)[0])
+	}
+
+	// the values of the snapshot are encoded as strings, not ints or
+	// interface{}s
+	_, err = new(LSHForest[int]).ReadFrom(bytes.NewReader(data))
+	if err != ErrValueType {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrValueType, err)
+	}
+	_, err = new(LSHForest[interface{}]).ReadFrom(bytes.NewReader(data))
+	if err != ErrValueType {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrValueType, err)
+	}
+}
+
+type point3 struct {
+	X, Y, Z int
+}
+
+func TestSnapshotPointers(t *testing.T) {
+	// neither nil pointers nor unregistered types are a problem for gob
+	// unless they're boxed in an interface{}
+	lshforest := NewDefaultTyped[*point3](3, Cosine)
+	if err := lshforest.Insert(&[]float64{1, 2, 3}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := lshforest.Insert(&[]float64{3, 2, 1}, &point3{3, 2, 1}); err != nil {
+		t.Fatal(err)
+	}
+	loaded := new(LSHForest[*point3])
+	if _, err := loaded.ReadFrom(bytes.NewReader(snapshot(t,
+		lshforest))); err != nil {
+		t.Fatal(err)
+	}
+	if value, _, err := loaded.Get(0); err != nil || value != nil {
+		t.Fatalf("expected: (<nil>) | got: (%v) (%v)", value, err)
+	}
+	value, _, err := loaded.Get(1)
+	if err != nil || !reflect.DeepEqual(value, &point3{3, 2, 1}) {
+		t.Fatalf("expected: (&{3 2 1}) | got: (%v) (%v)", value, err)
+	}
+}
+
+func TestSnapshotSparse(t *testing.T) {
+	lshforest := NewDefault(1000, Cosine, WithSeed(4))
+	vector, _ := sparse.New([]uint{999, 3}, []float64{1, -2})
+	if err := lshforest.InsertSparse(vector, "a"); err != nil {
+		t.Fatal(err)
+	}
+	dense := vector.Dense(1000)
+	if err := lshforest.Insert(&dense, "b"); err != nil {
+		t.Fatal(err)
+	}
+	data := snapshot(t, lshforest)
+
+	loaded := new(LSHForest[interface{}])
+	if _, err := loaded.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(snapshot(t, loaded), data) {
+		t.Fatal("snapshot of the loaded forest differs")
+	}
+	_, got, err := loaded.GetSparse(0)
+	if err != nil || !reflect.DeepEqual(got, vector) {
+		t.Fatalf("expected: (%v) | got: (%v, %v)", vector, got, err)
+	}
+	expected, err := lshforest.QueryResultsSparse(vector, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := loaded.QueryResultsSparse(vector, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("expected: (%+v) | got: (%+v)", *expected, *results)
+	}
+}
+
+func TestSnapshotFloat32(t *testing.T) {
+	lshforest := NewDefault(3, Euclidean, WithSeed(5))
+	vector := []float32{1.5, -2, 0.25}
+	if err := lshforest.InsertFloat32(&vector, "a"); err != nil {
+		t.Fatal(err)
+	}
+	data := snapshot(t, lshforest)
+
+	loaded := new(LSHForest[interface{}])
+	if _, err := loaded.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(snapshot(t, loaded), data) {
+		t.Fatal("snapshot of the loaded forest differs")
+	}
+	_, got, err := loaded.GetFloat32(0)
+	if err != nil || !reflect.DeepEqual(*got, vector) {
+		t.Fatalf("expected: (%v) | got: (%v, %v)", vector, got, err)
+	}
+	expected, err := lshforest.QueryResultsFloat32(&vector, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := loaded.QueryResultsFloat32(&vector, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("expected: (%+v) | got: (%+v)", *expected, *results)
+	}
+}
+
+func TestSnapshotStream(t *testing.T) {
+	lshforest := NewDefault(3, Cosine, WithSeed(6))
+	if err := lshforest.Insert(&[]float64{1, 2, 3}, "a"); err != nil {
+		t.Fatal(err)
+	}
+	data := snapshot(t, lshforest)
+	trailer := []byte("next")
+	stream := append(append([]byte{}, data...), trailer...)
+
+	// io.MultiReader isn't an io.ByteReader, bytes.Reader is
+	for _, r := range []io.Reader{io.MultiReader(bytes.NewReader(stream)),
+		bytes.NewReader(stream)} {
+		n, err := new(LSHForest[interface{}]).ReadFrom(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rest, _ := io.ReadAll(r)
+		if n != int64(len(data)) || !bytes.Equal(rest, trailer) {
+			t.Fatalf("expected: (%v, %q) | got: (%v, %q)", len(data), trailer,
+				n, rest)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package lshforest
+
+import (
+	"context"
+	"github.com/justinfargnoli/lshforest/pkg/hash"
+	"github.com/justinfargnoli/lshforest/pkg/lshtree"
+	"github.com/justinfargnoli/lshforest/pkg/sparse"
+	"math"
+)
+
+// point is a vector of an element or query, which is either dense, dense with
+// float32 components, or sparse. Exactly one of its fields is set
+type point struct {
+	dense   *[]float64
+	dense32 *[]float32
+	sparse  *sparse.Vector
+}
+
+func elementPoint[V any](element lshtree.Element[V]) point {
+	return point{dense: element.Vector, dense32: element.Vector32,
+		sparse: element.Sparse}
+}
+
+// newEntry constructs the entry of the vector p and the value
+func newEntry[V any](p point, value V) entry[V] {
+	return entry[V]{Data: &lshtree.Data[V]{Vector: p.dense, Vector32: p.dense32,
+		Sparse: p.sparse, Value: value}}
+}
+
+// point returns the vector of the entry
+func (e entry[V]) point() point {
+	return point{dense: e.Vector, dense32: e.Vector32, sparse: e.Sparse}
+}
+
+// setPoint replaces the vector of the entry, and so of its elements, with p
+func (e entry[V]) setPoint(p point) {
+	e.Vector, e.Vector32, e.Sparse = p.dense, p.dense32, p.sparse
+}
+
+// element constructs the lshtree.Element of the entry, which shares its
+// vector and value
+func (e entry[V]) element(id uint,
+	signature hash.Signature) lshtree.Element[V] {
+	return lshtree.NewSharedElement(id, signature, e.Data)
+}
+
+// checkPoint returns an error if p can't be compared with metric against
+// vectors of dimension dim
+func checkPoint(p point, metric, dim uint) error {
+	if p.dense32 != nil {
+		return checkVector(p.dense32, metric, dim)
+	}
+	if p.sparse == nil {
+		return checkVector(p.dense, metric, dim)
+	}
+	for i, x := range p.sparse.Values() {
+		if err := checkFinite(x, p.sparse.Indices()[i]); err != nil {
+			return err
+		}
+	}
+	if metric != Euclidean && p.sparse.Len() == 0 {
+		return ErrNonZero
+	}
+	if p.sparse.Dim() > dim {
+		return ErrEqDim
+	}
+	return nil
+}
+
+// hash returns the hash of p by the hasher of tree i
+func (f *LSHForest[V]) hash(i int, p point) hash.Signature {
+	switch {
+	case p.sparse != nil:
+		return f.hashers[i].(hash.SparseHasher).HashSparse(p.sparse)
+	case p.dense32 != nil:
+		return f.hashers[i].(hash.Float32Hasher).Hash32(p.dense32)
+	}
+	return f.hashers[i].Hash(p.dense)
+}
+
+// score returns the similarity of each element to query
+func score[V any](similarity func(point, point) float64,
+	query point) func(lshtree.Element[V]) float64 {
+	return func(element lshtree.Element[V]) float64 {
+		return similarity(query, elementPoint(element))
+	}
+}
+
+// InsertSparse puts the sparse vector into the LSHForest, as Insert does. Its
+// hashes touch only its non-zero components, and it's never densified
+func (f *LSHForest[V]) InsertSparse(vector *sparse.Vector, value V) error {
+	_, err := f.InsertSparseID(vector, value)
+	return err
+}
+
+// InsertSparseID puts the sparse vector into the LSHForest, as InsertSparse
+// does, and returns the id it's given
+func (f *LSHForest[V]) InsertSparseID(vector *sparse.Vector,
+	value V) (uint, error) {
+	return f.insertNext(newEntry(point{sparse: vector}, value))
+}
+
+// GetSparse returns the value and sparse vector of the element with the given
+// id. The vector is nil if the element was inserted with a dense vector, as
+// Get's is if it was inserted with InsertSparse
+func (f *LSHForest[V]) GetSparse(id uint) (V, *sparse.Vector, error) {
+	f.lock()
+	defer f.unlock()
+	entry, ok := f.entries[id]
+	if !ok {
+		var zero V
+		return zero, nil, ErrNotFound
+	}
+	return entry.Value, entry.Sparse, nil
+}
+
+// QuerySparse returns a list of the m values most similar to the sparse query
+// vector, as Query does
+func (f *LSHForest[V]) QuerySparse(vector *sparse.Vector, m uint,
+	opts ...QueryOption) (*[]V, error) {
+	results, err := f.QueryResultsSparse(vector, m, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return resultValues(results), nil
+}
+
+// QueryResultsSparse returns a list of results sorted by similarity to the
+// sparse query vector, as QueryResults does. Probes are ignored
+func (f *LSHForest[V]) QueryResultsSparse(vector *sparse.Vector, m uint,
+	opts ...QueryOption) (*[]Result[V], error) {
+	return f.QueryResultsSparseContext(context.Background(), vector, m,
+		opts...)
+}
+
+// QueryResultsSparseContext is QueryResultsSparse which stops once ctx is
+// done, as QueryResultsContext does
+func (f *LSHForest[V]) QueryResultsSparseContext(ctx context.Context,
+	vector *sparse.Vector, m uint, opts ...QueryOption) (*[]Result[V], error) {
+	return f.queryResults(ctx, point{sparse: vector}, m, opts)
+}
+
+// InsertSparse puts the sparse vector into the index, as LSHForest.InsertSparse
+// does
+func (e *Exact[V]) InsertSparse(vector *sparse.Vector, value V) error {
+	_, err := e.InsertSparseID(vector, value)
+	return err
+}
+
+// InsertSparseID puts the sparse vector into the index, as
+// LSHForest.InsertSparseID does
+func (e *Exact[V]) InsertSparseID(vector *sparse.Vector,
+	value V) (uint, error) {
+	return e.insertNext(point{sparse: vector}, value)
+}
+
+// QuerySparse returns a list of the m values most similar to the sparse query
+// vector, sorted by similarity
+func (e *Exact[V]) QuerySparse(vector *sparse.Vector, m uint,
+	opts ...QueryOption) (*[]V, error) {
+	results, err := e.QueryResultsSparse(vector, m, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return resultValues(results), nil
+}
+
+// QueryResultsSparse returns a list of the m results most similar to the
+// sparse query vector, sorted by similarity
+func (e *Exact[V]) QueryResultsSparse(vector *sparse.Vector, m uint,
+	opts ...QueryOption) (*[]Result[V], error) {
+	return e.queryResults(point{sparse: vector}, m, opts)
+}
+
+// forms are the forms of a similarity metric for each pair of kinds of vector.
+// Every metric is symmetric, so one form serves a pair in either order
+type forms struct {
+	dense   func(*[]float64, *[]float64) float64
+	dense32 func(*[]float64, *[]float32) float64
+	float32 func(*[]float32, *[]float32) float64
+	sparse  func(*sparse.Vector, *sparse.Vector) float64
+	mixed   func(*sparse.Vector, *[]float64) float64
+	mixed32 func(*sparse.Vector, *[]float32) float64
+}
+
+// kind orders the kinds of point as the forms take them: sparse, then dense,
+// then float32
+func (p point) kind() int {
+	switch {
+	case p.sparse != nil:
+		return 0
+	case p.dense != nil:
+		return 1
+	}
+	return 2
+}
+
+// similarity returns the similarity of p1 and p2 by the form for their kinds
+func (m forms) similarity(p1, p2 point) float64 {
+	if p1.kind() > p2.kind() {
+		p1, p2 = p2, p1
+	}
+	switch {
+	case p1.sparse != nil && p2.sparse != nil:
+		return m.sparse(p1.sparse, p2.sparse)
+	case p1.sparse != nil && p2.dense != nil:
+		return m.mixed(p1.sparse, p2.dense)
+	case p1.sparse != nil:
+		return m.mixed32(p1.sparse, p2.dense32)
+	case p1.dense != nil && p2.dense != nil:
+		return m.dense(p1.dense, p2.dense)
+	case p1.dense != nil:
+		return m.dense32(p1.dense, p2.dense32)
+	}
+	return m.float32(p1.dense32, p2.dense32)
+}
+
+func sparseCosine(v1, v2 *sparse.Vector) float64 {
+	magnitudes := v1.Norm() * v2.Norm()
+	if magnitudes == 0 {
+		panic("lshforest cosine(): vector has magnitude of zero")
+	}
+	return v1.Dot(v2) / magnitudes
+}
+
+func mixedCosine[F hash.Float](v1 *sparse.Vector, v2 *[]F) float64 {
+	magnitudes := v1.Norm() * magnitude(v2)
+	if magnitudes == 0 {
+		panic("lshforest cosine(): vector has magnitude of zero")
+	}
+	var dotProduct float64
+	for i, index := range v1.Indices() {
+		dotProduct += v1.Values()[i] * float64((*v2)[index])
+	}
+	return dotProduct / magnitudes
+}
+
+func sparseJaccard(v1, v2 *sparse.Vector) float64 {
+	intersection := v1.Overlap(v2)
+	union := v1.Len() + v2.Len() - intersection
+	if union == 0 {
+		panic("lshforest jaccard(): vector has magnitude of zero")
+	}
+	return float64(intersection) / float64(union)
+}
+
+func mixedJaccard[F hash.Float](v1 *sparse.Vector, v2 *[]F) float64 {
+	var intersection, nonZero int // nonZero counts the non-zeros of v2
+	for _, x := range *v2 {
+		if x != 0 {
+			nonZero++
+		}
+	}
+	for _, index := range v1.Indices() {
+		if int(index) < len(*v2) && (*v2)[index] != 0 {
+			intersection++
+		}
+	}
+	union := v1.Len() + nonZero - intersection
+	if union == 0 {
+		panic("lshforest jaccard(): vector has magnitude of zero")
+	}
+	return float64(intersection) / float64(union)
+}
+
+func sparseEuclidean(v1, v2 *sparse.Vector) float64 {
+	return 1 / (1 + v1.Distance(v2))
+}
+
+func mixedEuclidean[F hash.Float](v1 *sparse.Vector, v2 *[]F) float64 {
+	var distance float64
+	next := 0 // the position in v1.Indices() of the next non-zero component
+	for i, y := range *v2 {
+		var x float64
+		if next < v1.Len() && v1.Indices()[next] == uint(i) {
+			x = v1.Values()[next]
+			next++
+		}
+		distance += (x - float64(y)) * (x - float64(y))
+	}
+	return 1 / (1 + math.Sqrt(distance))
+}
@@ -0,0 +1,182 @@
+package sparse
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+var (
+	// ErrLength is thrown when a vector is given a different number of
+	// indices and values
+	ErrLength = errors.New("len(indices) != len(values)")
+	// ErrDuplicate is thrown when a vector is given an index more than once
+	ErrDuplicate = errors.New("sparse vector has a duplicate index")
+)
+
+// Vector is a vector stored as the indices and values of its non-zero
+// components, in ascending order of index
+type Vector struct {
+	indices []uint
+	values  []float64
+}
+
+// New constructs the vector whose component indices[i] is values[i] and whose
+// other components are zero. Zero values are dropped
+func New(indices []uint, values []float64) (*Vector, error) {
+	if len(indices) != len(values) {
+		return nil, ErrLength
+	}
+	order := make([]int, len(indices))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return indices[order[i]] < indices[order[j]]
+	})
+	v := &Vector{}
+	for i, j := range order {
+		if i > 0 && indices[j] == indices[order[i-1]] {
+			return nil, ErrDuplicate
+		}
+		if values[j] != 0 {
+			v.indices = append(v.indices, indices[j])
+			v.values = append(v.values, values[j])
+		}
+	}
+	return v, nil
+}
+
+// FromDense constructs the sparse form of a dense vector
+func FromDense(dense *[]float64) *Vector {
+	v := &Vector{}
+	for i, x := range *dense {
+		if x != 0 {
+			v.indices = append(v.indices, uint(i))
+			v.values = append(v.values, x)
+		}
+	}
+	return v
+}
+
+// Len returns the number of non-zero components of the vector
+func (v *Vector) Len() int {
+	return len(v.indices)
+}
+
+// Dim returns the smallest dimension the vector fits in, one more than its
+// largest index
+func (v *Vector) Dim() uint {
+	if len(v.indices) == 0 {
+		return 0
+	}
+	return v.indices[len(v.indices)-1] + 1
+}
+
+// Indices returns the indices of the non-zero components in ascending order.
+// It shouldn't be modified
+func (v *Vector) Indices() []uint {
+	return v.indices
+}
+
+// Values returns the non-zero components in the order of Indices. It
+// shouldn't be modified
+func (v *Vector) Values() []float64 {
+	return v.values
+}
+
+// Dense returns the vector as a dense vector of dimension dim, which must be
+// at least Dim
+func (v *Vector) Dense(dim uint) []float64 {
+	dense := make([]float64, dim)
+	for i, index := range v.indices {
+		dense[index] = v.values[i]
+	}
+	return dense
+}
+
+// Norm returns the euclidean norm of the vector
+func (v *Vector) Norm() float64 {
+	var norm float64
+	for _, x := range v.values {
+		norm += x * x
+	}
+	return math.Sqrt(norm)
+}
+
+// Dot returns the dot product of the vector and other
+func (v *Vector) Dot(other *Vector) float64 {
+	var dotProduct float64
+	v.merge(other, func(x, y float64) {
+		dotProduct += x * y
+	})
+	return dotProduct
+}
+
+// DotDense returns the dot product of the vector and a dense vector, which
+// touches only the vector's non-zero components
+func (v *Vector) DotDense(dense *[]float64) float64 {
+	var dotProduct float64
+	for i, index := range v.indices {
+		dotProduct += v.values[i] * (*dense)[index]
+	}
+	return dotProduct
+}
+
+// Overlap returns the number of indices at which both the vector and other
+// are non-zero
+func (v *Vector) Overlap(other *Vector) int {
+	var overlap int
+	v.merge(other, func(x, y float64) {
+		if x != 0 && y != 0 {
+			overlap++
+		}
+	})
+	return overlap
+}
+
+// Distance returns the euclidean distance between the vector and other
+func (v *Vector) Distance(other *Vector) float64 {
+	var distance float64
+	v.merge(other, func(x, y float64) {
+		distance += (x - y) * (x - y)
+	})
+	return math.Sqrt(distance)
+}
+
+// DistanceDense returns the euclidean distance between the vector and a dense
+// vector
+func (v *Vector) DistanceDense(dense *[]float64) float64 {
+	var distance float64
+	next := 0 // the position in v.indices of the next non-zero component
+	for i, y := range *dense {
+		var x float64
+		if next < len(v.indices) && v.indices[next] == uint(i) {
+			x = v.values[next]
+			next++
+		}
+		distance += (x - y) * (x - y)
+	}
+	return math.Sqrt(distance)
+}
+
+// merge calls fn with the components of the vector and other at each index
+// where either is non-zero, in ascending order of index
+func (v *Vector) merge(other *Vector, fn func(x, y float64)) {
+	i, j := 0, 0
+	for i < len(v.indices) || j < len(other.indices) {
+		switch {
+		case j == len(other.indices) ||
+			i < len(v.indices) && v.indices[i] < other.indices[j]:
+			fn(v.values[i], 0)
+			i++
+		case i == len(v.indices) || other.indices[j] < v.indices[i]:
+			fn(0, other.values[j])
+			j++
+		default:
+			fn(v.values[i], other.values[j])
+			i++
+			j++
+		}
+	}
+}
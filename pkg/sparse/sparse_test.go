@@ -0,0 +1,64 @@
+package sparse
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	v, err := New([]uint{7, 2, 4, 0}, []float64{1, -2, 0, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v.Indices(), []uint{0, 2, 7}) ||
+		!reflect.DeepEqual(v.Values(), []float64{3, -2, 1}) {
+		t.Fatalf("got: (%v, %v)", v.Indices(), v.Values())
+	}
+	if v.Len() != 3 || v.Dim() != 8 {
+		t.Fatalf("expected: (3, 8) | got: (%v, %v)", v.Len(), v.Dim())
+	}
+	if dense := v.Dense(9); !reflect.DeepEqual(dense,
+		[]float64{3, 0, -2, 0, 0, 0, 0, 1, 0}) {
+		t.Fatalf("got: (%v)", dense)
+	}
+	if !reflect.DeepEqual(FromDense(&[]float64{3, 0, -2, 0, 0, 0, 0, 1}), v) {
+		t.Fatal("FromDense() differs from New()")
+	}
+
+	if _, err := New([]uint{1, 2}, []float64{1}); err != ErrLength {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrLength, err)
+	}
+	if _, err := New([]uint{1, 2, 1}, []float64{1, 2, 0}); err != ErrDuplicate {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrDuplicate, err)
+	}
+	if empty, err := New(nil, nil); err != nil || empty.Len() != 0 ||
+		empty.Dim() != 0 {
+		t.Fatalf("got: (%v, %v)", empty, err)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	d1 := []float64{1, 0, 2, 0, -3}
+	d2 := []float64{0, 4, 2, 0, 1}
+	v1, v2 := FromDense(&d1), FromDense(&d2)
+
+	// 2 * 2 + -3 * 1
+	if v1.Dot(v2) != 1 || v1.DotDense(&d2) != 1 || v2.DotDense(&d1) != 1 {
+		t.Fatalf("expected: (1) | got: (%v, %v)", v1.Dot(v2),
+			v1.DotDense(&d2))
+	}
+	if v1.Norm() != math.Sqrt(14) {
+		t.Fatalf("expected: (%v) | got: (%v)", math.Sqrt(14), v1.Norm())
+	}
+	if v1.Overlap(v2) != 2 || v2.Overlap(v1) != 2 {
+		t.Fatalf("expected: (2) | got: (%v)", v1.Overlap(v2))
+	}
+	// 1 + 16 + 0 + 0 + 16
+	distance := math.Sqrt(33)
+	if v1.Distance(v2) != distance || v1.DistanceDense(&d2) != distance ||
+		v2.DistanceDense(&d1) != distance {
+		t.Fatalf("expected: (%v) | got: (%v, %v)", distance,
+			v1.Distance(v2), v1.DistanceDense(&d2))
+	}
+}
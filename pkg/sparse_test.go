@@ -0,0 +1,142 @@
+package lshforest
+
+import (
+	"github.com/justinfargnoli/lshforest/pkg/sparse"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// sparseVectors returns n vectors of dimension dim with k non-zero components
+// in both their dense and sparse forms
+func sparseVectors(r *rand.Rand, n, dim, k int) ([][]float64,
+	[]*sparse.Vector) {
+	var dense [][]float64
+	var vectors []*sparse.Vector
+	for i := 0; i < n; i++ {
+		vector := make([]float64, dim)
+		for _, j := range r.Perm(dim)[:k] {
+			vector[j] = r.NormFloat64()
+		}
+		dense = append(dense, vector)
+		vectors = append(vectors, sparse.FromDense(&vector))
+	}
+	return dense, vectors
+}
+
+func TestSparse(t *testing.T) {
+	const dim = 1000
+	r := rand.New(rand.NewSource(8))
+	dense, vectors := sparseVectors(r, 200, dim, 10)
+	var values []interface{}
+	for i := range dense {
+		values = append(values, i)
+	}
+
+	for _, metric := range []uint{Cosine, Jaccard, Euclidean} {
+		// every other element of mixed is sparse
+		lshforest := New(5, 10, dim, metric, WithSeed(2))
+		mixed := New(5, 10, dim, metric, WithSeed(2))
+		if err := lshforest.InsertAll(&dense, &values); err != nil {
+			t.Fatal(err)
+		}
+		for i := range dense {
+			var err error
+			if i%2 == 0 {
+				err = mixed.InsertSparse(vectors[i], i)
+			} else {
+				err = mixed.Insert(&dense[i], i)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for i := 0; i < 20; i++ {
+			expected, err := lshforest.QueryResults(&dense[i], 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := mixed.QueryResultsSparse(vectors[i], 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(*got) != len(*expected) {
+				t.Fatalf("metric (%v) expected: (%v) results | got: (%v)",
+					metric, len(*expected), len(*got))
+			}
+			for j := range *expected {
+				e, g := (*expected)[j], (*got)[j]
+				if g.ID != e.ID ||
+					math.Abs(g.Similarity-e.Similarity) > 1e-12 ||
+					(g.Sparse != nil) != (g.ID%2 == 0) {
+					t.Fatalf("metric (%v) expected: (%+v) | got: (%+v)", metric,
+						e, g)
+				}
+			}
+		}
+	}
+}
+
+func TestSparseInvalid(t *testing.T) {
+	lshforest := NewDefault(10, Cosine)
+	outside, _ := sparse.New([]uint{10}, []float64{1})
+	if err := lshforest.InsertSparse(outside, "a"); err != ErrEqDim {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrEqDim, err)
+	}
+	if err := lshforest.InsertSparse(&sparse.Vector{}, "a"); err != ErrNonZero {
+		t.Fatalf("expected: (%v) | got: (%v)", ErrNonZero, err)
+	}
+
+	vector, _ := sparse.New([]uint{9, 0}, []float64{1, 2})
+	if err := lshforest.InsertSparse(vector, "a"); err != nil {
+		t.Fatal(err)
+	}
+	value, got, err := lshforest.GetSparse(0)
+	if err != nil || value != "a" || got != vector {
+		t.Fatalf("expected: (a, %v) | got: (%v, %v, %v)", vector, value, got,
+			err)
+	}
+	if _, dense, _ := lshforest.Get(0); dense != nil {
+		t.Fatalf("expected: (nil) | got: (%v)", dense)
+	}
+	values, err := lshforest.QuerySparse(vector, 1)
+	if err != nil || (*values)[0] != "a" {
+		t.Fatalf("expected: ([a]) | got: (%v, %v)", values, err)
+	}
+}
+
+func TestExactSparse(t *testing.T) {
+	const dim = 100
+	r := rand.New(rand.NewSource(9))
+	dense, vectors := sparseVectors(r, 50, dim, 5)
+	var values []interface{}
+	for i := range dense {
+		values = append(values, i)
+	}
+	exact := NewExact(dim, Cosine)
+	if err := exact.InsertAll(&dense, &values); err != nil {
+		t.Fatal(err)
+	}
+	sparseExact := NewExact(dim, Cosine)
+	for i := range vectors {
+		if err := sparseExact.InsertSparse(vectors[i], i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range dense {
+		expected, err := exact.Query(&dense[i], 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := sparseExact.QuerySparse(vectors[i], 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := range *expected {
+			if (*got)[j] != (*expected)[j] {
+				t.Fatalf("expected: (%v) | got: (%v)", *expected, *got)
+			}
+		}
+	}
+}